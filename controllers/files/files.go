@@ -0,0 +1,140 @@
+// Package files provides functions for rendering files,
+// e.g. generated reports, user uploads, or blobs from storage.
+// Range requests, conditional headers, and content type detection
+// are handled the same way http.ServeContent does.
+package files
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Files is a controller with helper functions
+// for rendering files.
+type Files struct {
+	// ContentType is a value of the Content-Type header.
+	// If not specified explicitly, it is detected using
+	// the extension of the file name or its content.
+	ContentType string
+
+	// ETag is a value of the ETag header, e.g. a hash of the content.
+	// If it is specified, If-None-Match and If-Match headers
+	// of the requests are honoured.
+	ETag string
+}
+
+// RenderFile is an action that renders a file from disk with the path.
+// If there is no such file, 404 error is returned.
+func (c *Files) RenderFile(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		d, err := f.Stat()
+		if err != nil || d.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		c.serve(w, r, d.Name(), d.ModTime(), f)
+	})
+}
+
+// RenderReader is an action that renders content with the name
+// and modification time. The name is used for detection of the content
+// type, the modification time is used for conditional requests and may
+// be zero. If the content is an io.Closer, it is closed after rendering.
+func (c *Files) RenderReader(name string, modtime time.Time, content io.ReadSeeker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cl, ok := content.(io.Closer); ok {
+			defer cl.Close()
+		}
+		c.serve(w, r, name, modtime, content)
+	})
+}
+
+// RenderAttachment is similar to RenderReader but makes browsers
+// download the content and save it as a file with the name.
+func (c *Files) RenderAttachment(name string, modtime time.Time, content io.ReadSeeker) http.Handler {
+	return c.disposition("attachment", name, c.RenderReader(name, modtime, content))
+}
+
+// RenderInline is similar to RenderReader but makes browsers display
+// the content and use the name if the user saves it.
+func (c *Files) RenderInline(name string, modtime time.Time, content io.ReadSeeker) http.Handler {
+	return c.disposition("inline", name, c.RenderReader(name, modtime, content))
+}
+
+// disposition returns a handler that sets Content-Disposition header
+// and calls the next handler.
+func (c *Files) disposition(typ, name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", ContentDisposition(typ, name))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serve sets the headers of the controller and serves the content.
+func (c *Files) serve(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	if c.ContentType != "" {
+		w.Header().Set("Content-Type", c.ContentType)
+	}
+	if c.ETag != "" {
+		w.Header().Set("ETag", c.ETag)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// ContentDisposition returns a value of Content-Disposition header
+// of the type, e.g. "attachment" or "inline", with the file name
+// as described in RFC 6266. Names with non-ASCII characters are encoded
+// using RFC 5987 and an ASCII fallback is added for old clients:
+//
+//	attachment; filename="_.txt"; filename*=UTF-8''%D1%84.txt
+func ContentDisposition(typ, name string) string {
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	fallback := make([]byte, 0, len(name))
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\' || r < ' ' || r == 0x7f:
+			fallback = append(fallback, '_')
+		case r >= utf8.RuneSelf:
+			fallback = append(fallback, '_')
+			ascii = false
+		default:
+			fallback = append(fallback, byte(r))
+		}
+	}
+	v := typ + `; filename="` + string(fallback) + `"`
+	if !ascii {
+		v += "; filename*=UTF-8''" + encode(name)
+	}
+	return v
+}
+
+// encode percent-encodes all the characters of the string
+// that are not attr-char of RFC 5987.
+func encode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
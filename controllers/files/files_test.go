@@ -0,0 +1,82 @@
+package files
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	for _, v := range []struct {
+		typ, name, exp string
+	}{
+		{"attachment", "report.pdf", `attachment; filename="report.pdf"`},
+		{"inline", `../my "file".txt`, `inline; filename="my _file_.txt"`},
+		{"attachment", "отчёт 1.pdf", `attachment; filename="_____ 1.pdf"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82%201.pdf`},
+	} {
+		if act := ContentDisposition(v.typ, v.name); act != v.exp {
+			t.Errorf(`"%s": expected %s, got %s.`, v.name, v.exp, act)
+		}
+	}
+}
+
+func TestFiles_RenderReader(t *testing.T) {
+	mod := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, v := range []struct {
+		hdr, val string
+		c        *Files
+		status   int
+		body     string
+	}{
+		{"", "", &Files{}, http.StatusOK, "0123456789"},
+		{"Range", "bytes=2-4", &Files{}, http.StatusPartialContent, "234"},
+		{"If-Modified-Since", mod.Format(http.TimeFormat), &Files{}, http.StatusNotModified, ""},
+		{"If-None-Match", `"v1"`, &Files{ETag: `"v1"`}, http.StatusNotModified, ""},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		if v.hdr != "" {
+			r.Header.Set(v.hdr, v.val)
+		}
+		v.c.RenderAttachment("a.txt", mod, strings.NewReader("0123456789")).ServeHTTP(w, r)
+		if w.Code != v.status || w.Body.String() != v.body {
+			t.Errorf(`%s: expected %d "%s", got %d "%s".`, v.hdr, v.status, v.body, w.Code, w.Body)
+		}
+		if w.Header().Get("Content-Disposition") != `attachment; filename="a.txt"` {
+			t.Errorf("Content-Disposition expected, got %v.", w.Header())
+		}
+	}
+}
+
+func TestFiles_RenderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "a.json")
+	ioutil.WriteFile(p, []byte("{}"), 0644)
+
+	c := &Files{}
+	for _, v := range []struct {
+		path   string
+		status int
+		ctype  string
+	}{
+		{p, http.StatusOK, "application/json"},
+		{dir, http.StatusNotFound, "text/plain; charset=utf-8"},
+		{filepath.Join(dir, "b.json"), http.StatusNotFound, "text/plain; charset=utf-8"},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		c.RenderFile(v.path).ServeHTTP(w, r)
+		if w.Code != v.status || w.Header().Get("Content-Type") != v.ctype {
+			t.Errorf(`"%s": expected %d "%s", got %d %v.`, v.path, v.status, v.ctype, w.Code, w.Header())
+		}
+	}
+}
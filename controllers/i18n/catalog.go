@@ -0,0 +1,170 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// categories are CLDR plural categories.
+var categories = map[string]bool{
+	"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true,
+}
+
+// parseJSON parses a JSON catalog and adds its messages to the map.
+func parseJSON(b []byte, ms map[string]message) error {
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return flatten("", v, ms)
+}
+
+// flatten adds messages of the object to the map. Keys of nested objects
+// are joined using dots unless the objects are plural forms.
+func flatten(prefix string, v map[string]interface{}, ms map[string]message) error {
+	for k, v := range v {
+		key := prefix + k
+		switch v := v.(type) {
+		case string:
+			ms[key] = message{"other": v}
+		case map[string]interface{}:
+			if m, ok := plural(v); ok {
+				ms[key] = m
+				continue
+			}
+			if err := flatten(key+".", v, ms); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf(`message "%s" must be a string or an object, got %T`, key, v)
+		}
+	}
+	return nil
+}
+
+// plural returns plural forms of a message if all keys
+// of the object are plural categories.
+func plural(v map[string]interface{}) (message, bool) {
+	if len(v) == 0 {
+		return nil, false
+	}
+	m := message{}
+	for k, v := range v {
+		s, ok := v.(string)
+		if !categories[k] || !ok {
+			return nil, false
+		}
+		m[k] = s
+	}
+	return m, true
+}
+
+// parsePO parses a gettext catalog and adds its messages to the map.
+// Plural forms are mapped to categories using the plural rule.
+// Fuzzy and untranslated messages are ignored. Messages with
+// a context are stored as "context\x04msgid" the same way gettext does.
+func parsePO(b []byte, r *PluralRule, ms map[string]message) error {
+	var (
+		ctxt, id string
+		strs     map[int]*string
+		fuzzy    bool
+		last     *string
+		n        int
+	)
+	add := func() {
+		if id != "" && !fuzzy && len(strs) > 0 {
+			m := message{}
+			for i, s := range strs {
+				if *s == "" {
+					continue
+				}
+				form := "other"
+				if i < len(r.Forms) {
+					form = r.Forms[i]
+				}
+				m[form] = *s
+			}
+			if len(m) > 0 {
+				if ctxt != "" {
+					id = ctxt + "\x04" + id
+				}
+				ms[id] = m
+			}
+		}
+		ctxt, id, strs, fuzzy, last = "", "", nil, false, nil
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#,"):
+			if strs != nil {
+				add()
+			}
+			fuzzy = strings.Contains(line, "fuzzy")
+			continue
+		case line[0] == '#':
+			continue
+		case line[0] == '"':
+			if last == nil {
+				return fmt.Errorf("line %d: unexpected string", n)
+			}
+			v, err := strconv.Unquote(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", n, err)
+			}
+			*last += v
+			continue
+		}
+
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return fmt.Errorf("line %d: unexpected %q", n, line)
+		}
+		kw := line[:i]
+		v, err := strconv.Unquote(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		switch {
+		case kw == "msgctxt":
+			if strs != nil {
+				add()
+			}
+			ctxt, last = v, &ctxt
+		case kw == "msgid":
+			if strs != nil {
+				add()
+			}
+			id, last = v, &id
+		case kw == "msgid_plural":
+			// Plural source is not needed, msgid is used as a key.
+			last = new(string)
+		case kw == "msgstr" || strings.HasPrefix(kw, "msgstr["):
+			j := 0
+			if kw != "msgstr" {
+				j, err = strconv.Atoi(strings.TrimSuffix(kw[len("msgstr["):], "]"))
+				if err != nil {
+					return fmt.Errorf("line %d: unexpected %q", n, kw)
+				}
+			}
+			if strs == nil {
+				strs = map[int]*string{}
+			}
+			strs[j] = &v
+			last = &v
+		default:
+			return fmt.Errorf("line %d: unexpected %q", n, kw)
+		}
+	}
+	add()
+	return s.Err()
+}
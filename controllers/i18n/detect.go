@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source returns locales requested by the request in order of preference.
+type Source func(r *http.Request) []string
+
+// Sources are checked one by one to choose a locale of the request.
+// The first requested locale that exists is used.
+var Sources = []Source{Param, Cookie, AcceptLanguage}
+
+// Detect returns locales requested by the request
+// according to Sources in order of preference.
+func Detect(r *http.Request) []string {
+	var res []string
+	for _, s := range Sources {
+		res = append(res, s(r)...)
+	}
+	return res
+}
+
+// Param is a Source that returns a locale from the route or query
+// parameter specified by "i18n:param" flag.
+func Param(r *http.Request) []string {
+	v := r.Form.Get(*param)
+	if v == "" {
+		v = r.URL.Query().Get(*param)
+	}
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+// Cookie is a Source that returns a locale from the cookie
+// specified by "i18n:cookie" flag.
+func Cookie(r *http.Request) []string {
+	c, err := r.Cookie(*cookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	return []string{c.Value}
+}
+
+// AcceptLanguage is a Source that returns locales
+// from Accept-Language header sorted by their quality.
+func AcceptLanguage(r *http.Request) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var ls []lang
+	for _, h := range r.Header["Accept-Language"] {
+		for _, v := range strings.Split(h, ",") {
+			ps := strings.Split(v, ";")
+			l := lang{tag: strings.TrimSpace(ps[0]), q: 1}
+			for _, p := range ps[1:] {
+				if p = strings.TrimSpace(p); strings.HasPrefix(p, "q=") {
+					if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+						l.q = q
+					}
+				}
+			}
+			if l.tag != "" && l.tag != "*" && l.q > 0 {
+				ls = append(ls, l)
+			}
+		}
+	}
+	sort.SliceStable(ls, func(i, j int) bool {
+		return ls[i].q > ls[j].q
+	})
+	res := make([]string, len(ls))
+	for i := range ls {
+		res[i] = ls[i].tag
+	}
+	return res
+}
@@ -0,0 +1,386 @@
+// Package i18n implements internationalization of applications.
+//
+// Message catalogs are loaded from files named after locales,
+// e.g. "fr.json" or "pt-BR.po", of the directory specified by
+// "i18n:path" flag. JSON catalogs are objects where values are
+// either messages or objects with plural forms, nested objects
+// are flattened using dots:
+//
+//	{
+//		"hello": "Hello, {name}!",
+//		"cart": {
+//			"items": {"one": "{count} item", "other": "{count} items"}
+//		}
+//	}
+//
+// Gettext catalogs use msgid as the key and msgstr[N]
+// as plural forms in the order of the language's plural rule.
+//
+// A locale of the request is chosen using Sources: a route parameter,
+// a cookie, and Accept-Language header are checked by default.
+// Use I18n as a parent of your controllers to access it using c.Locale.
+// To choose the locale from a session, call c.SetLocale from
+// the magic Before action of your controller.
+//
+// To use the package with templates, register T function,
+// the request's locale as "locale" in the Context,
+// and template variants with locale suffixes:
+//
+//	i18n.RegisterFuncs(templates.Funcs)
+//	i18n.RegisterContextFuncs(templates.ContextFuncs)
+//	templates.Variants = i18n.Variants
+//
+//	{%T .locale "cart.items" "count" .n%}
+//
+// Then templates with locale suffixes, e.g. "App/Index.fr.html",
+// are rendered instead of the regular ones when they exist.
+package i18n
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+var (
+	dir       = flag.String("i18n:path", "./messages/", "path to the directory with message catalogs")
+	defLocale = flag.String("i18n:default", "en", "locale that is used if no other locale is requested")
+	cookie    = flag.String("i18n:cookie", "lang", "name of the cookie with a locale")
+	param     = flag.String("i18n:param", "lang", "name of the route or query parameter with a locale")
+
+	// FS is a file system catalogs are loaded from. If it is nil,
+	// the directory specified by "i18n:path" flag is used.
+	FS fs.FS
+
+	// locales are loaded locales by their tags.
+	locales = map[string]*Locale{}
+)
+
+// RegisterFuncs adds T function to the function map of templates.
+func RegisterFuncs(m template.FuncMap) {
+	m["T"] = T
+}
+
+// RegisterContextFuncs adds a function that returns the locale
+// of the request as "locale" to the map, e.g. templates.ContextFuncs.
+func RegisterContextFuncs(m map[string]func(*http.Request) interface{}) {
+	m["locale"] = func(r *http.Request) interface{} {
+		return FromRequest(r)
+	}
+}
+
+// Variants returns tags of the request's locale and its parents,
+// so templates with locale suffixes are preferred. It is intended
+// to be used as templates.Variants.
+func Variants(r *http.Request) []string {
+	return FromRequest(r).tags()
+}
+
+// I18n is a controller that makes Locale field available
+// for your actions when you're using this controller as a parent.
+type I18n struct {
+	Locale *Locale
+
+	Request  *http.Request       `bind:"request"`
+	Response http.ResponseWriter `bind:"response"`
+}
+
+// Before is a magic action that chooses the locale of the request.
+func (c *I18n) Before() http.Handler {
+	c.Locale = FromRequest(c.Request)
+	return nil
+}
+
+// SetLocale changes c.Locale and stores it in a cookie, so it is used
+// for next requests. It returns false if there is no such locale.
+// The request is not modified, so to render templates in the new
+// locale during the current request, pass c.Locale to them explicitly.
+func (c *I18n) SetLocale(tag string) bool {
+	l, ok := locales[canonical(tag)]
+	if !ok {
+		return false
+	}
+	c.Locale = l
+	http.SetCookie(c.Response, &http.Cookie{
+		Name:   *cookie,
+		Value:  l.Tag,
+		Path:   "/",
+		MaxAge: 365 * 24 * 60 * 60,
+	})
+	return true
+}
+
+// contextKey is a key of locale in the context.
+type contextKey struct{}
+
+// NewContext returns a copy of the context with the locale.
+// It may be used by hooks and middleware that choose
+// locales of requests themselves.
+func NewContext(ctx context.Context, l *Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromRequest returns a locale stored in the context of the request
+// or, if there is none, the locale chosen using Sources.
+func FromRequest(r *http.Request) *Locale {
+	if l, ok := r.Context().Value(contextKey{}).(*Locale); ok {
+		return l
+	}
+	return Get(Detect(r)...)
+}
+
+// Get returns the first of the requested locales that exists.
+// Locales are matched exactly or by their language, e.g. "fr-CA"
+// matches "fr" if there is no "fr-CA" catalog. If none of them exist,
+// the default locale is returned.
+func Get(tags ...string) *Locale {
+	for _, t := range tags {
+		t = canonical(t)
+		if l, ok := locales[t]; ok {
+			return l
+		}
+		if i := strings.IndexByte(t, '-'); i > 0 {
+			if l, ok := locales[t[:i]]; ok {
+				return l
+			}
+		}
+	}
+	return Default()
+}
+
+// Default returns the locale specified by "i18n:default" flag.
+func Default() *Locale {
+	if l, ok := locales[canonical(*defLocale)]; ok {
+		return l
+	}
+	return &Locale{Tag: canonical(*defLocale), rule: rule(*defLocale)}
+}
+
+// Locale is a catalog of messages of a language.
+type Locale struct {
+	// Tag is a BCP 47 language tag of the locale, e.g. "en" or "pt-BR".
+	Tag string
+
+	messages map[string]message
+	rule     *PluralRule
+	parent   *Locale // Locale that is used for missing messages.
+}
+
+// T returns a message with the key translated to the locale.
+// Arguments are pairs of names and values that replace
+// "{name}" placeholders of the message, or a single map.
+// If there is a "count" argument, the plural form of the message
+// is chosen using it. If there is no such message in the locale
+// and its parents, the key is used as the message.
+func (l *Locale) T(key string, args ...interface{}) string {
+	vs := values(args)
+	for c := l; c != nil; c = c.parent {
+		m, ok := c.messages[key]
+		if !ok {
+			continue
+		}
+		form := "other"
+		if n, ok := vs["count"]; ok {
+			if i, ok := integer(n); ok {
+				if i < 0 {
+					i = -i
+				}
+				form = c.rule.Forms[c.rule.Form(i)]
+			}
+		}
+		return interpolate(m.get(form), vs)
+	}
+	return interpolate(key, vs)
+}
+
+// tags returns tags of the locale and its parents.
+func (l *Locale) tags() []string {
+	var res []string
+	for c := l; c != nil; c = c.parent {
+		res = append(res, c.Tag)
+	}
+	return res
+}
+
+// T is a template function that translates the message with the key
+// to the locale. If the locale is nil, the default one is used.
+// See Locale.T for details.
+func T(l *Locale, key string, args ...interface{}) string {
+	if l == nil {
+		l = Default()
+	}
+	return l.T(key, args...)
+}
+
+// message is a translated message. Plural forms
+// are stored by their categories, e.g. "one" or "other".
+type message map[string]string
+
+// get returns the requested form of the message
+// falling back to "other" and then to any form.
+func (m message) get(form string) string {
+	if s, ok := m[form]; ok {
+		return s
+	}
+	if s, ok := m["other"]; ok {
+		return s
+	}
+	for _, s := range m {
+		return s
+	}
+	return ""
+}
+
+// values converts arguments of T to a map.
+func values(args []interface{}) map[string]interface{} {
+	if len(args) == 1 {
+		if m, ok := args[0].(map[string]interface{}); ok {
+			return m
+		}
+	}
+	vs := make(map[string]interface{}, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		vs[fmt.Sprint(args[i])] = args[i+1]
+	}
+	return vs
+}
+
+// interpolate replaces "{name}" placeholders of the message with values.
+func interpolate(s string, vs map[string]interface{}) string {
+	if len(vs) == 0 || !strings.Contains(s, "{") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			break
+		}
+		v, ok := vs[s[i+1:i+j]]
+		if !ok {
+			b.WriteString(s[:i+j+1])
+			s = s[i+j+1:]
+			continue
+		}
+		b.WriteString(s[:i])
+		fmt.Fprint(&b, v)
+		s = s[i+j+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// integer converts the value to an int if it is an integer.
+func integer(v interface{}) (int, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// Init is a function that is used for initialization
+// of the I18n controller. It loads message catalogs.
+func Init(url.Values) {
+	if err := Load(); err != nil {
+		log.Panicf(`Cannot load message catalogs. Error: %v.`, err)
+	}
+}
+
+// Load loads message catalogs from the FS or from the directory
+// specified by "i18n:path" flag if the FS is nil.
+func Load() error {
+	fsys := FS
+	if fsys == nil {
+		fsys = os.DirFS(filepath.Clean(*dir))
+	}
+	ms := map[string]map[string]message{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := path.Ext(p)
+		if ext != ".json" && ext != ".po" {
+			return nil
+		}
+		tag := canonical(strings.TrimSuffix(path.Base(p), ext))
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if ms[tag] == nil {
+			ms[tag] = map[string]message{}
+		}
+		if ext == ".json" {
+			err = parseJSON(b, ms[tag])
+		} else {
+			err = parsePO(b, rule(tag), ms[tag])
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Allocate the locales and link them to their parents,
+	// e.g. "fr-CA" to "fr" and "fr" to the default one.
+	def := canonical(*defLocale)
+	if _, ok := ms[def]; !ok {
+		ms[def] = map[string]message{}
+	}
+	ls := map[string]*Locale{}
+	for tag, m := range ms {
+		ls[tag] = &Locale{Tag: tag, messages: m, rule: rule(tag)}
+	}
+	for tag, l := range ls {
+		if tag == def {
+			continue
+		}
+		l.parent = ls[def]
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if p, ok := ls[tag[:i]]; ok {
+				l.parent = p
+			}
+		}
+	}
+	locales = ls
+	return nil
+}
+
+// canonical returns the tag in its canonical form,
+// e.g. "pt-BR" for "pt_br".
+func canonical(tag string) string {
+	ps := strings.Split(strings.Replace(strings.TrimSpace(tag), "_", "-", -1), "-")
+	ps[0] = strings.ToLower(ps[0])
+	for i := 1; i < len(ps); i++ {
+		switch len(ps[i]) {
+		case 2:
+			ps[i] = strings.ToUpper(ps[i])
+		case 4:
+			ps[i] = strings.ToUpper(ps[i][:1]) + strings.ToLower(ps[i][1:])
+		default:
+			ps[i] = strings.ToLower(ps[i])
+		}
+	}
+	return strings.Join(ps, "-")
+}
@@ -0,0 +1,169 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/goaltools/contrib/controllers/templates"
+)
+
+func testLoad(t *testing.T) {
+	FS = fstest.MapFS{
+		"en.json": {Data: []byte(`{
+			"hello": "Hello, {name}!",
+			"cart": {"items": {"one": "{count} item", "other": "{count} items"}},
+			"only.en": "English"
+		}`)},
+		"fr.po": {Data: []byte(`# French.
+msgid ""
+msgstr ""
+"Language: fr\n"
+
+msgid "hello"
+msgstr "Bonjour, "
+"{name} !"
+
+msgid "cart.items"
+msgid_plural "{count} items"
+msgstr[0] "{count} article"
+msgstr[1] "{count} articles"
+
+#, fuzzy
+msgid "fuzzy"
+msgstr "Flou"
+
+msgctxt "menu"
+msgid "open"
+msgstr "Ouvrir"
+`)},
+		"fr_ca.json": {Data: []byte(`{"hello": "Allô, {name}!"}`)},
+		"ru.json": {Data: []byte(`{
+			"cart": {"items": {"one": "{count} товар", "few": "{count} товара", "many": "{count} товаров"}}
+		}`)},
+	}
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocale_T(t *testing.T) {
+	testLoad(t)
+	defer func() {
+		FS = nil
+	}()
+
+	for _, v := range []struct {
+		tag, key string
+		args     []interface{}
+		exp      string
+	}{
+		{"en", "hello", []interface{}{"name", "Bob"}, "Hello, Bob!"},
+		{"en", "hello", []interface{}{map[string]interface{}{"name": "Ann"}}, "Hello, Ann!"},
+		{"en", "cart.items", []interface{}{"count", 1}, "1 item"},
+		{"en", "cart.items", []interface{}{"count", 0}, "0 items"},
+		{"en", "unknown {x}", []interface{}{"y", 1}, "unknown {x}"},
+		{"fr", "hello", []interface{}{"name", "Bob"}, "Bonjour, Bob !"},
+		{"fr", "cart.items", []interface{}{"count", 0}, "0 article"},
+		{"fr", "cart.items", []interface{}{"count", int64(2)}, "2 articles"},
+		{"fr", "fuzzy", nil, "fuzzy"},
+		{"fr", "menu\x04open", nil, "Ouvrir"},
+		{"fr", "only.en", nil, "English"},
+		{"fr-CA", "hello", []interface{}{"name", "Bob"}, "Allô, Bob!"},
+		{"fr-CA", "cart.items", []interface{}{"count", 3}, "3 articles"},
+		{"ru", "cart.items", []interface{}{"count", 21}, "21 товар"},
+		{"ru", "cart.items", []interface{}{"count", 3}, "3 товара"},
+		{"ru", "cart.items", []interface{}{"count", 11}, "11 товаров"},
+	} {
+		if act := Get(v.tag).T(v.key, v.args...); act != v.exp {
+			t.Errorf(`%s "%s": expected "%s", got "%s".`, v.tag, v.key, v.exp, act)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	testLoad(t)
+	defer func() {
+		FS = nil
+	}()
+
+	for _, v := range []struct {
+		url, cookie, lang string
+		exp               string
+	}{
+		{"/", "", "", "en"},
+		{"/", "", "de, fr-CH;q=0.9, ru;q=0.95", "ru"},
+		{"/", "", "fr-CA;q=0.5, ru;q=0", "fr-CA"},
+		{"/", "fr", "ru", "fr"},
+		{"/?lang=ru", "fr", "en", "ru"},
+		{"/?lang=xx", "", "", "en"},
+	} {
+		r, _ := http.NewRequest("GET", v.url, nil)
+		if v.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: v.cookie})
+		}
+		r.Header.Set("Accept-Language", v.lang)
+		if act := FromRequest(r).Tag; act != v.exp {
+			t.Errorf(`"%s", "%s", "%s": expected "%s", got "%s".`, v.url, v.cookie, v.lang, v.exp, act)
+		}
+	}
+}
+
+func TestI18n_SetLocale(t *testing.T) {
+	testLoad(t)
+	defer func() {
+		FS = nil
+	}()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	c := &I18n{Request: r, Response: w}
+	c.Before()
+	if c.Locale.Tag != "en" {
+		t.Errorf(`Expected "en", got "%s".`, c.Locale.Tag)
+	}
+	if c.SetLocale("de") {
+		t.Error("Unknown locale is not expected to be set.")
+	}
+	if !c.SetLocale("fr") || c.Locale.Tag != "fr" || w.Header().Get("Set-Cookie") == "" {
+		t.Errorf("Locale is expected to be stored in the cookie, got %v.", w.Header())
+	}
+	if FromRequest(r).Tag != "en" {
+		t.Error("Request is not expected to be modified.")
+	}
+	if act := T(c.Locale, "hello", "name", "Bob"); act != "Bonjour, Bob !" {
+		t.Errorf(`Unexpected translation "%s".`, act)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	testLoad(t)
+	RegisterFuncs(templates.Funcs)
+	RegisterContextFuncs(templates.ContextFuncs)
+	templates.Variants = Variants
+	templates.FS = fstest.MapFS{
+		"App/Index.html":    {Data: []byte(`{%T .locale "hello" "name" "Bob"%}`)},
+		"App/Index.ru.html": {Data: []byte(`ru`)},
+	}
+	defer func() {
+		FS, templates.FS, templates.Variants = nil, nil, nil
+		delete(templates.Funcs, "T")
+		delete(templates.ContextFuncs, "locale")
+	}()
+	if err := templates.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	for lang, exp := range map[string]string{"fr": "Bonjour, Bob !", "ru": "ru", "": "Hello, Bob!"} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", lang)
+		c := &templates.Templates{Request: r}
+		c.Before()
+		w := httptest.NewRecorder()
+		c.RenderTemplate("App/Index.html").ServeHTTP(w, r)
+		if act := w.Body.String(); act != exp {
+			t.Errorf(`Language "%s": expected "%s", got "%s".`, lang, exp, act)
+		}
+	}
+}
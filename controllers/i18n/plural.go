@@ -0,0 +1,125 @@
+package i18n
+
+import "strings"
+
+// PluralRule describes plural forms of a language.
+type PluralRule struct {
+	// Forms are CLDR plural categories of the language, e.g. "one" and
+	// "other", in the order of msgstr[N] of gettext catalogs.
+	Forms []string
+
+	// Form returns an index of the form that is used for the number.
+	Form func(n int) int
+}
+
+// PluralRules are plural rules of languages. Languages that are
+// not in the map use the same rule as English.
+var PluralRules = map[string]*PluralRule{
+	"en": oneOther, "de": oneOther, "nl": oneOther, "sv": oneOther,
+	"da": oneOther, "nb": oneOther, "no": oneOther, "fi": oneOther,
+	"et": oneOther, "it": oneOther, "es": oneOther, "pt": oneOther,
+	"el": oneOther, "hu": oneOther, "tr": oneOther, "bg": oneOther,
+
+	"fr": {
+		Forms: []string{"one", "other"},
+		Form: func(n int) int {
+			if n == 0 || n == 1 {
+				return 0
+			}
+			return 1
+		},
+	},
+
+	"ja": other, "zh": other, "ko": other, "vi": other, "th": other, "id": other,
+
+	"ru": slavic, "uk": slavic, "be": slavic,
+
+	"pl": {
+		Forms: []string{"one", "few", "many"},
+		Form: func(n int) int {
+			switch {
+			case n == 1:
+				return 0
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return 1
+			}
+			return 2
+		},
+	},
+
+	"cs": czech, "sk": czech,
+
+	"ar": {
+		Forms: []string{"zero", "one", "two", "few", "many", "other"},
+		Form: func(n int) int {
+			switch {
+			case n == 0:
+				return 0
+			case n == 1:
+				return 1
+			case n == 2:
+				return 2
+			case n%100 >= 3 && n%100 <= 10:
+				return 3
+			case n%100 >= 11:
+				return 4
+			}
+			return 5
+		},
+	},
+}
+
+var (
+	oneOther = &PluralRule{
+		Forms: []string{"one", "other"},
+		Form: func(n int) int {
+			if n == 1 {
+				return 0
+			}
+			return 1
+		},
+	}
+
+	other = &PluralRule{
+		Forms: []string{"other"},
+		Form:  func(int) int { return 0 },
+	}
+
+	slavic = &PluralRule{
+		Forms: []string{"one", "few", "many"},
+		Form: func(n int) int {
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return 0
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return 1
+			}
+			return 2
+		},
+	}
+
+	czech = &PluralRule{
+		Forms: []string{"one", "few", "other"},
+		Form: func(n int) int {
+			switch {
+			case n == 1:
+				return 0
+			case n >= 2 && n <= 4:
+				return 1
+			}
+			return 2
+		},
+	}
+)
+
+// rule returns a plural rule of the locale's language.
+func rule(tag string) *PluralRule {
+	lang := canonical(tag)
+	if i := strings.IndexByte(lang, '-'); i > 0 {
+		lang = lang[:i]
+	}
+	if r, ok := PluralRules[lang]; ok {
+		return r
+	}
+	return oneOther
+}
@@ -0,0 +1,126 @@
+// Package requestids implements propagation of request IDs
+// that are used for correlation of log messages.
+// An ID is taken from X-Request-ID header of the request or generated,
+// it is stored in the request's context and echoed in the response.
+//
+// Use the package as a hook of the denco router, so the ID is available
+// to all handlers and other hooks:
+//
+//	router := r.NewRouter().Hook(requestids.Hook{})
+//
+// And use RequestIDs as a parent of your controllers to access it
+// from actions using c.RequestID.
+package requestids
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"net/http"
+)
+
+// Header is a name of the header that is used for passing request IDs.
+const Header = "X-Request-ID"
+
+// maxLen is the maximum length of an incoming request ID.
+const maxLen = 128
+
+var (
+	trust = flag.Bool("requestids:trust.incoming", true, "use request IDs received from clients")
+)
+
+// RequestIDs is a controller that makes RequestID field
+// available for your actions when you're using this
+// controller as a parent.
+type RequestIDs struct {
+	RequestID string
+
+	Request  *http.Request       `bind:"request"`
+	Response http.ResponseWriter `bind:"response"`
+}
+
+// Before is a magic action that gets the request ID from
+// the request's context. If Hook is not used and there is no ID,
+// it is obtained from the request's header or generated and
+// echoed in the response.
+func (c *RequestIDs) Before() http.Handler {
+	c.RequestID = FromContext(c.Request.Context())
+	if c.RequestID == "" {
+		c.RequestID = fromHeader(c.Request)
+		c.Response.Header().Set(Header, c.RequestID)
+	}
+	return nil
+}
+
+// Hook is a denco router hook that obtains a request ID,
+// stores it in the context of the request, and echoes it
+// in the response's header.
+type Hook struct{}
+
+// Dispatch is used to implement denco.Hook interface.
+func (Hook) Dispatch(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler) {
+	id := FromContext(r.Context())
+	if id == "" {
+		id = fromHeader(r)
+		r = r.WithContext(NewContext(r.Context(), id))
+	}
+	w.Header().Set(Header, id)
+	next.ServeHTTP(w, r)
+}
+
+// Handler returns a handler that does the same as Hook
+// for requests served by h.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Hook{}.Dispatch(w, r, "", h)
+	})
+}
+
+// contextKey is a key of request ID in the context.
+type contextKey struct{}
+
+// NewContext returns a copy of the context with the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns a request ID stored in the context
+// or an empty string if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New generates a new random request ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// fromHeader returns a request ID from the request's header
+// if it is valid and trusted. Otherwise, a new one is generated.
+func fromHeader(r *http.Request) string {
+	if id := r.Header.Get(Header); *trust && valid(id) {
+		return id
+	}
+	return New()
+}
+
+// valid checks whether the incoming ID is not empty, not too long,
+// and contains only printable ASCII characters, so it is safe
+// to include it in logs and headers.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package requestids
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	for _, v := range []struct {
+		header string
+		trust  bool
+		same   bool
+	}{
+		{"abc-123", true, true},
+		{"abc-123", false, false},
+		{"", true, false},
+		{"bad id\n", true, false},
+		{strings.Repeat("x", maxLen+1), true, false},
+	} {
+		*trust = v.trust
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set(Header, v.header)
+		w := httptest.NewRecorder()
+
+		var id string
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := &RequestIDs{Request: r, Response: w}
+			c.Before()
+			id = c.RequestID
+		})).ServeHTTP(w, r)
+
+		if id == "" || w.Header().Get(Header) != id {
+			t.Errorf(`Expected ID "%s" to be echoed, got "%s".`, id, w.Header().Get(Header))
+		}
+		if (id == v.header) != v.same {
+			t.Errorf(`Incoming ID "%s", trusted: %v. Unexpected result "%s".`, v.header, v.trust, id)
+		}
+	}
+	*trust = true
+}
+
+func TestRequestIDs_Before(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	c := &RequestIDs{Request: r, Response: w}
+	c.Before()
+	if len(c.RequestID) != 32 || w.Header().Get(Header) != c.RequestID {
+		t.Errorf(`Expected a new ID to be generated and echoed, got "%s", "%s".`, c.RequestID, w.Header().Get(Header))
+	}
+}
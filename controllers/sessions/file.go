@@ -0,0 +1,186 @@
+package sessions
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store that keeps data of sessions in files
+// of a directory and their signed IDs in cookies. Sessions expire
+// after TTL of inactivity, i.e. TTL after modification times
+// of their files.
+type FileStore struct {
+	Dir string
+	TTL time.Duration
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// fileSession is a format of session files.
+type fileSession struct {
+	Data map[string]string `json:"data"`
+}
+
+// NewFileStore allocates and returns a new FileStore
+// creating the directory if it does not exist.
+func NewFileStore(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		Dir:   dir,
+		TTL:   ttl,
+		swept: time.Now(),
+	}, nil
+}
+
+// Load returns data of the session with the ID from the cookie value.
+func (f *FileStore) Load(value string) (map[string]string, error) {
+	id, err := decodeID(value)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(f.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.expired(fi, time.Now()) {
+		os.Remove(f.path(id))
+		return nil, ErrNotFound
+	}
+	ss, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return ss.Data, nil
+}
+
+// Save stores the data of the session and returns a cookie value
+// with its signed ID. A new ID is generated for new sessions.
+func (f *FileStore) Save(value string, data map[string]string) (string, error) {
+	id, err := decodeID(value)
+	if err != nil {
+		id = newID()
+	}
+	now := time.Now()
+	if err := f.write(id, &fileSession{Data: data}); err != nil {
+		return "", err
+	}
+	f.sweep(now)
+	return encodeID(id)
+}
+
+// Touch extends the lifetime of the session with the ID
+// from the cookie value by updating the modification time
+// of its file. The data is not rewritten, so concurrent
+// saves are never lost.
+func (f *FileStore) Touch(value string) error {
+	id, err := decodeID(value)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = os.Chtimes(f.path(id), now, now)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Delete removes the session with the ID from the cookie value.
+func (f *FileStore) Delete(value string) error {
+	id, err := decodeID(value)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(f.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// read reads the session file with the ID.
+func (f *FileStore) read(id string) (*fileSession, error) {
+	b, err := ioutil.ReadFile(f.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	ss := &fileSession{}
+	if err := json.Unmarshal(b, ss); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// write writes the session file with the ID. A temporary file
+// is written first, so concurrent requests never read
+// a partially written session.
+func (f *FileStore) write(id string, ss *fileSession) error {
+	b, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(id))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// expired returns true if the session file
+// has not been modified for TTL.
+func (f *FileStore) expired(fi os.FileInfo, now time.Time) bool {
+	return now.After(fi.ModTime().Add(f.TTL))
+}
+
+// path returns a path of the session file with the ID.
+func (f *FileStore) path(id string) string {
+	return filepath.Join(f.Dir, id)
+}
+
+// sweep removes the expired session files. It is done at most
+// once per TTL, so saving is not slowed down much.
+func (f *FileStore) sweep(now time.Time) {
+	f.mu.Lock()
+	if now.Sub(f.swept) < f.TTL {
+		f.mu.Unlock()
+		return
+	}
+	f.swept = now
+	f.mu.Unlock()
+
+	fs, err := ioutil.ReadDir(f.Dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range fs {
+		if !validID(fi.Name()) {
+			continue
+		}
+		if f.expired(fi, now) {
+			os.Remove(f.path(fi.Name()))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+)
+
+// flashKey is a key of the session the flash messages
+// of the next request are stored at.
+const flashKey = "_flash"
+
+// Flash are messages by their keys that are shown once,
+// e.g. after a redirect.
+type Flash map[string]string
+
+// SetFlash adds the message to the flash of the next request.
+// It is available as c.Flash during that request only:
+//
+//	c.SetFlash("success", "Saved.")
+//	return c.Redirect(...)
+func (c *Sessions) SetFlash(key, msg string) {
+	f := Flash{}
+	c.Session.JSON(flashKey, &f)
+	f[key] = msg
+	c.Session.SetJSON(flashKey, f)
+}
+
+// loadFlash moves the flash messages from the session to c.Flash.
+func (c *Sessions) loadFlash() {
+	c.Flash = Flash{}
+	if err := c.Session.JSON(flashKey, &c.Flash); err != ErrNotFound {
+		delete(c.Session, flashKey)
+	}
+}
+
+// Hook is a denco router hook that stores the flash messages
+// of the request in its context, so they are available
+// to handlers that have no access to the Sessions controller,
+// e.g. templates. See RegisterContextFuncs.
+type Hook struct{}
+
+// Dispatch is used to implement denco.Hook interface.
+func (Hook) Dispatch(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler) {
+	f := Flash{}
+	if cookie, err := r.Cookie(*cookieName); err == nil {
+		if m, err := Default.Load(cookie.Value); err == nil {
+			Session(m).JSON(flashKey, &f)
+		}
+	}
+	next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), flashContextKey{}, f)))
+}
+
+// Handler returns a handler that does the same as Hook
+// for requests served by h.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Hook{}.Dispatch(w, r, "", h)
+	})
+}
+
+// flashContextKey is a key of flash messages in the context.
+type flashContextKey struct{}
+
+// FlashFromRequest returns the flash messages stored
+// in the context of the request by Hook or nil if there are none.
+func FlashFromRequest(r *http.Request) Flash {
+	f, _ := r.Context().Value(flashContextKey{}).(Flash)
+	return f
+}
+
+// RegisterContextFuncs adds a function that returns the flash messages
+// of the request as "flash" to the map, e.g. templates.ContextFuncs.
+// Hook must be used for the messages to be available:
+//
+//	sessions.RegisterContextFuncs(templates.ContextFuncs)
+//	router := r.NewRouter().Hook(sessions.Hook{})
+//
+//	{%with .flash.success%}<p>{%.%}</p>{%end%}
+func RegisterContextFuncs(m map[string]func(*http.Request) interface{}) {
+	m["flash"] = func(r *http.Request) interface{} {
+		return FlashFromRequest(r)
+	}
+}
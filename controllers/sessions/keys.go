@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+// KeyPair is a pair of keys that are used for signing and
+// encryption of cookies. Encryption is optional.
+type KeyPair struct {
+	Hash       []byte
+	Encryption []byte
+}
+
+// validate makes sure the keys can be used.
+func (k KeyPair) validate() error {
+	if len(k.Hash) == 0 {
+		return errors.New("hash key is empty")
+	}
+	switch len(k.Encryption) {
+	case 0, 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("encryption key must be 16, 24, or 32 bytes long, got %d", len(k.Encryption))
+}
+
+// block returns the encryption key or nil if there is none.
+func (k KeyPair) block() []byte {
+	if len(k.Encryption) == 0 {
+		return nil
+	}
+	return k.Encryption
+}
+
+// parseKeys parses key pairs in the format of "sessions:old.keys" flag:
+//
+//	oldHashKey:oldEncryptionKey olderHashKey
+func parseKeys(s string) ([]KeyPair, error) {
+	var res []KeyPair
+	for _, v := range strings.Fields(s) {
+		ps := strings.SplitN(v, ":", 2)
+		k := KeyPair{Hash: []byte(ps[0])}
+		if len(ps) == 2 {
+			k.Encryption = []byte(ps[1])
+		}
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+		res = append(res, k)
+	}
+	return res, nil
+}
+
+// encode encodes the value using the current keys.
+func encode(value interface{}) (string, error) {
+	return securecookie.EncodeMulti(*cookieName, value, codecs[0])
+}
+
+// decode decodes the cookie value using the current
+// or any of the old keys.
+func decode(value string, dst interface{}) error {
+	return securecookie.DecodeMulti(*cookieName, value, dst, codecs...)
+}
@@ -0,0 +1,109 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps data of sessions in memory
+// and their signed IDs in cookies. Sessions expire after TTL
+// of inactivity. The data is lost when the app is restarted.
+type MemoryStore struct {
+	TTL time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+	swept    time.Time
+}
+
+// memorySession is a session stored in memory.
+type memorySession struct {
+	data    map[string]string
+	expires time.Time
+}
+
+// NewMemoryStore allocates and returns a new MemoryStore.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		TTL:      ttl,
+		sessions: map[string]*memorySession{},
+		swept:    time.Now(),
+	}
+}
+
+// Load returns data of the session with the ID from the cookie value.
+func (m *MemoryStore) Load(value string) (map[string]string, error) {
+	id, err := decodeID(value)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ss, ok := m.sessions[id]
+	if !ok || time.Now().After(ss.expires) {
+		delete(m.sessions, id)
+		return nil, ErrNotFound
+	}
+	return copyMap(ss.data), nil
+}
+
+// Save stores the data of the session and returns a cookie value
+// with its signed ID. A new ID is generated for new sessions.
+func (m *MemoryStore) Save(value string, data map[string]string) (string, error) {
+	id, err := decodeID(value)
+	if err != nil {
+		id = newID()
+	}
+	now := time.Now()
+	m.mu.Lock()
+	m.sessions[id] = &memorySession{
+		data:    copyMap(data),
+		expires: now.Add(m.TTL),
+	}
+	m.sweep(now)
+	m.mu.Unlock()
+	return encodeID(id)
+}
+
+// Touch extends the lifetime of the session with the ID
+// from the cookie value.
+func (m *MemoryStore) Touch(value string) error {
+	id, err := decodeID(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ss, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	ss.expires = time.Now().Add(m.TTL)
+	return nil
+}
+
+// Delete removes the session with the ID from the cookie value.
+func (m *MemoryStore) Delete(value string) error {
+	id, err := decodeID(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// sweep removes the expired sessions. It is done at most
+// once per TTL, so saving is not slowed down much.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.swept) < m.TTL {
+		return
+	}
+	m.swept = now
+	for id, ss := range m.sessions {
+		if now.After(ss.expires) {
+			delete(m.sessions, id)
+		}
+	}
+}
@@ -1,11 +1,27 @@
-// Package sessions implements COOKIE based sessions.
+// Package sessions implements sessions that are stored
+// in cookies or on the server with signed IDs in cookies.
+// The store is chosen using "sessions:store" flag or by setting
+// the Default variable before Init is called.
+//
+// Cookies are signed using "sessions:app.secret" and encrypted
+// if "sessions:encryption.key" is set. To rotate the keys, move
+// the old pair to "sessions:old.keys", so existing cookies
+// are still accepted.
+//
+// Flash messages that are set using SetFlash are available
+// during the next request as c.Flash and, if Hook is used
+// and RegisterContextFuncs is called, as "flash" in the Context
+// of templates.
 package sessions
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/securecookie"
@@ -15,59 +31,156 @@ var (
 	cookieName   = flag.String("sessions:cookie.name", "_Session", "name of the cookie with session data")
 	cookieDomain = flag.String("sessions:cookie.domain", "", "domain of cookie")
 	cookieSecure = flag.Bool("sessions:cookie.secure", false, "prevent transmission of a cookie in clear text")
+	cookiePath   = flag.String("sessions:cookie.path", "/", "path of cookie")
+	cookieSite   = flag.String("sessions:cookie.samesite", "lax", "SameSite attribute of cookie: lax, strict, none, or empty to omit it")
 
 	cookieMaxAge = flag.Int("sessions:cookie.maxage", 0, "time in seconds for when a cookie will be deleted")
 	cookieExpire = flag.String("sessions:cookie.expires.duration", "", "a time duration when a cookie expires")
 
 	httpOnly  = flag.Bool("sessions:cookie.http.only", false, "")
+	rolling   = flag.Bool("sessions:cookie.rolling", false, "write the cookie on every response, so its expiration is extended while the user is active")
 	appSecret = flag.String("sessions:app.secret", string(securecookie.GenerateRandomKey(64)), "")
+	encKey    = flag.String("sessions:encryption.key", "", "key of 16, 24, or 32 bytes for AES encryption of cookies")
+	oldKeys   = flag.String("sessions:old.keys", "", "space separated hash:encryption key pairs, newest first, that were used before")
+
+	// Keys are old hash and encryption key pairs, newest first.
+	// Cookies that were encoded using them are still decoded,
+	// while new cookies are encoded using "sessions:app.secret"
+	// and "sessions:encryption.key". Init uses them together with
+	// pairs of "sessions:old.keys" that follow them, Keys itself
+	// is not modified.
+	Keys []KeyPair
+
+	store     = flag.String("sessions:store", "cookie", "where session data is stored: cookie, memory, or file")
+	storePath = flag.String("sessions:store.path", "./sessions", "path to the directory with sessions of the file store")
+	storeTTL  = flag.Duration("sessions:store.ttl", 24*time.Hour, "how long inactive sessions are kept by the memory and file stores")
+
+	// Default is a store sessions are loaded from and saved to.
+	// If it is nil, Init allocates the store specified by "sessions:store".
+	Default Store
 
 	hashKey []byte
 
-	s *securecookie.SecureCookie
+	s      *securecookie.SecureCookie // Codec with the current keys.
+	codecs []securecookie.Codec       // Codecs with the current and old keys.
 
 	expireAfter *time.Duration
+	sameSite    http.SameSite
+
+	decodeErrors uint64
 )
 
 // Sessions is a controller that makes Session field
 // available for your actions when you're using this
 // controller as a parent.
 type Sessions struct {
-	Session map[string]string
+	Session Session
+
+	// Flash are messages that were set using SetFlash
+	// during the previous request.
+	Flash Flash
 
 	Request  *http.Request       `bind:"request"`
 	Response http.ResponseWriter `bind:"response"`
+
+	value string            // Value of the session cookie.
+	orig  map[string]string // Session data as it was loaded.
+	clear bool              // The cookie is invalid and must be removed.
 }
 
 // Before is a magic action that gets session info from a request
-// and initializes Session field.
+// and initializes Session field. Cookies that cannot be decoded,
+// e.g. because they were tampered with or have expired, are counted
+// and removed by After.
 func (c *Sessions) Before() http.Handler {
-	c.Session = map[string]string{}
+	c.Session = Session{}
 	if cookie, err := c.Request.Cookie(*cookieName); err == nil {
-		s.Decode(*cookieName, cookie.Value, &c.Session)
+		m, err := Default.Load(cookie.Value)
+		if err != nil {
+			atomic.AddUint64(&decodeErrors, 1)
+			if err != ErrNotFound {
+				log.Printf(`Cannot decode the session cookie of %s. Error: %v.`, c.Request.RemoteAddr, err)
+			}
+			c.clear = true
+		} else {
+			c.Session, c.value = m, cookie.Value
+		}
 	}
+	c.orig = copyMap(c.Session)
+	c.loadFlash()
 	return nil
 }
 
 // After is a magic action that will be executed at the very end of request
-// life cycle and is responsible for creating a signed cookie with session info.
+// life cycle and is responsible for saving the session to the store
+// and creating a signed cookie with session info or ID.
+// The cookie is not written if the session has not been changed unless
+// "sessions:cookie.rolling" is true, stores that implement Toucher
+// just extend the lifetime of such sessions.
 func (c *Sessions) After() http.Handler {
-	if encoded, err := s.Encode(*cookieName, c.Session); err == nil {
-		cookie := c.cookie(encoded)
-		http.SetCookie(c.Response, cookie)
+	if !c.changed() && (!*rolling || c.value == "") {
+		if t, ok := Default.(Toucher); ok && c.value != "" {
+			if err := t.Touch(c.value); err != nil {
+				log.Printf(`Cannot extend the session. Error: %v.`, err)
+			}
+		}
+		if c.clear {
+			cookie := c.cookie("")
+			cookie.MaxAge, cookie.Expires = -1, time.Unix(0, 0)
+			http.SetCookie(c.Response, cookie)
+		}
+		return nil
 	}
+	encoded, err := Default.Save(c.value, c.Session)
+	if err != nil {
+		log.Printf(`Cannot save the session. Error: %v.`, err)
+		return nil
+	}
+	http.SetCookie(c.Response, c.cookie(encoded))
 	return nil
 }
 
+// Destroy deletes the session from the store and removes the cookie.
+// Values that are set after Destroy is called are saved to a new session.
+func (c *Sessions) Destroy() error {
+	var err error
+	if c.value != "" {
+		err = Default.Delete(c.value)
+	}
+	c.Session, c.orig, c.value, c.clear = Session{}, map[string]string{}, "", true
+	return err
+}
+
+// changed returns true if the session data
+// differs from the data that was loaded.
+func (c *Sessions) changed() bool {
+	if len(c.Session) != len(c.orig) {
+		return true
+	}
+	for k, v := range c.Session {
+		if w, ok := c.orig[k]; !ok || w != v {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeErrors returns the number of session cookies that could not
+// be decoded, e.g. because they were tampered with or have expired.
+func DecodeErrors() uint64 {
+	return atomic.LoadUint64(&decodeErrors)
+}
+
 func (c *Sessions) cookie(data string) *http.Cookie {
 	cookie := &http.Cookie{
 		Name:     *cookieName,
 		Value:    data,
 		Domain:   *cookieDomain,
 		HttpOnly: *httpOnly,
-		Path:     "/",
+		Path:     *cookiePath,
 		Secure:   *cookieSecure,
 		MaxAge:   *cookieMaxAge,
+		SameSite: sameSite,
 	}
 	if expireAfter != nil {
 		cookie.Expires = time.Now().Local().Add(*expireAfter)
@@ -79,7 +192,62 @@ func (c *Sessions) cookie(data string) *http.Cookie {
 // Sessions controller.
 func Init(url.Values) {
 	hashKey = []byte(*appSecret)
-	s = securecookie.New(hashKey, nil)
+	if f := flag.Lookup("sessions:app.secret"); f != nil && f.DefValue == *appSecret {
+		log.Printf(`WARNING: "sessions:app.secret" is generated randomly, sessions will not survive restarts of the app.`)
+	}
+
+	// Allocate codecs for the current and old keys.
+	ks, err := parseKeys(*oldKeys)
+	if err != nil {
+		log.Panicf(`Cannot parse "sessions:old.keys". Error: %v.`, err)
+	}
+	ks = append([]KeyPair{{Hash: hashKey, Encryption: []byte(*encKey)}}, append(Keys, ks...)...)
+	codecs = make([]securecookie.Codec, len(ks))
+	for i, k := range ks {
+		if err := k.validate(); err != nil {
+			log.Panicf(`Incorrect session keys. Error: %v.`, err)
+		}
+		sc := securecookie.New(k.Hash, k.block())
+		if i == 0 {
+			s = sc
+		}
+		codecs[i] = sc
+	}
+
+	// Allocate the store unless it is set explicitly.
+	if Default == nil {
+		switch *store {
+		case "cookie":
+			Default = CookieStore{}
+		case "memory":
+			Default = NewMemoryStore(*storeTTL)
+		case "file":
+			fs, err := NewFileStore(*storePath, *storeTTL)
+			if err != nil {
+				log.Panicf(`Cannot allocate the file store of sessions. Error: %v.`, err)
+			}
+			Default = fs
+		default:
+			log.Panicf(`Unknown store of sessions "%s".`, *store)
+		}
+	}
+
+	// Make sure the cookie attributes are allowed by browsers.
+	switch strings.ToLower(*cookieSite) {
+	case "":
+		sameSite = 0 // The attribute is omitted.
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	default:
+		log.Panicf(`Unknown SameSite mode "%s" of the session cookie.`, *cookieSite)
+	}
+	if err := checkCookie(); err != nil {
+		log.Panicf(`Incorrect session cookie parameters. Error: %v.`, err)
+	}
 
 	// Convert "expiration" string to a time duration
 	// if it is not empty.
@@ -97,3 +265,19 @@ func Init(url.Values) {
 		log.Printf(`Parameter "cookie.maxage" is equal to %vs.`, *cookieMaxAge)
 	}
 }
+
+// checkCookie returns an error if browsers will reject the cookie
+// due to the prefix of its name or its SameSite mode.
+func checkCookie() error {
+	prefixed := strings.HasPrefix(*cookieName, "__Secure-") || strings.HasPrefix(*cookieName, "__Host-")
+	if prefixed && !*cookieSecure {
+		return fmt.Errorf(`cookie "%s" requires "sessions:cookie.secure"`, *cookieName)
+	}
+	if sameSite == http.SameSiteNoneMode && !*cookieSecure {
+		return fmt.Errorf(`SameSite mode "none" requires "sessions:cookie.secure"`)
+	}
+	if strings.HasPrefix(*cookieName, "__Host-") && (*cookieDomain != "" || *cookiePath != "/") {
+		return fmt.Errorf(`cookie "%s" must have no domain and "/" path`, *cookieName)
+	}
+	return nil
+}
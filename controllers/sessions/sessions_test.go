@@ -1,10 +1,16 @@
 package sessions
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorilla/securecookie"
 )
 
 func TestInit(t *testing.T) {
@@ -37,3 +43,385 @@ func TestCookieExpiration(t *testing.T) {
 		t.Errorf("Incorrect Max-Age parameter of a cookie. Expected %d, got %d.", *cookieMaxAge, cookie.MaxAge)
 	}
 }
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs, err := NewFileStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		Default = nil
+	}()
+
+	for _, st := range []Store{CookieStore{}, NewMemoryStore(time.Hour), fs} {
+		Default = st
+		Init(url.Values{})
+
+		// The first request creates a session.
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		c := &Sessions{Request: r, Response: w}
+		c.Before()
+		c.Session["user"] = "1"
+		c.After()
+		cookie := w.Result().Cookies()[0]
+
+		// The next one gets it.
+		r, _ = http.NewRequest("GET", "/", nil)
+		r.AddCookie(cookie)
+		c = &Sessions{Request: r, Response: httptest.NewRecorder()}
+		c.Before()
+		if c.Session["user"] != "1" {
+			t.Errorf("%T: session is expected to be loaded, got %v.", st, c.Session)
+		}
+
+		// Server-side sessions can be deleted.
+		if _, ok := st.(CookieStore); ok {
+			continue
+		}
+		if strings.Contains(cookie.Value, "user") || len(cookie.Value) > 200 {
+			t.Errorf("%T: only ID is expected in the cookie, got %s.", st, cookie.Value)
+		}
+		if err := st.Delete(cookie.Value); err != nil {
+			t.Error(err)
+		}
+		if _, err := st.Load(cookie.Value); err != ErrNotFound {
+			t.Errorf("%T: deleted session is not expected to be found, got %v.", st, err)
+		}
+	}
+}
+
+func TestMemoryStore_TTL(t *testing.T) {
+	Init(url.Values{})
+	m := NewMemoryStore(10 * time.Millisecond)
+	v, _ := m.Save("", map[string]string{"a": "b"})
+	time.Sleep(20 * time.Millisecond)
+	if _, err := m.Load(v); err != ErrNotFound {
+		t.Errorf("Expired session is not expected to be found, got %v.", err)
+	}
+	m.Save("", nil)
+	time.Sleep(20 * time.Millisecond)
+	m.Save("", nil)
+	if len(m.sessions) != 1 {
+		t.Errorf("Expired sessions are expected to be swept, got %d.", len(m.sessions))
+	}
+}
+
+func TestInit_Keys(t *testing.T) {
+	defer func() {
+		*appSecret, *encKey, *oldKeys = "secret", "", ""
+		Init(url.Values{})
+	}()
+
+	// Encode a value using old keys.
+	*appSecret, *encKey = "old", "0123456789abcdef"
+	Init(url.Values{})
+	old, err := encode("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// It is still decoded after rotation, while new values are encrypted
+	// using the new keys.
+	*appSecret, *encKey, *oldKeys = "new", "abcdef0123456789abcdef0123456789", "old:0123456789abcdef"
+	Init(url.Values{})
+	var v string
+	if err := decode(old, &v); err != nil || v != "value" {
+		t.Errorf(`Value encoded using old keys is expected to be decoded, got "%s", %v.`, v, err)
+	}
+	cur, _ := encode("value")
+	if err := securecookie.DecodeMulti(*cookieName, cur, &v, codecs[1]); err == nil {
+		t.Error("New values are not expected to be decoded using old keys.")
+	}
+
+	// Without the old keys the old value is rejected.
+	*oldKeys = ""
+	Init(url.Values{})
+	if err := decode(old, &v); err == nil {
+		t.Error("Value encoded using removed keys is not expected to be decoded.")
+	}
+
+	if _, err := parseKeys("a:short"); err == nil {
+		t.Error("Incorrect encryption key is expected to be rejected.")
+	}
+}
+
+func TestSessions_After(t *testing.T) {
+	Init(url.Values{})
+	defer func() {
+		*rolling = false
+	}()
+	do := func(cookie *http.Cookie, f func(map[string]string)) (*Sessions, []*http.Cookie) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		c := &Sessions{Request: r, Response: w}
+		c.Before()
+		f(c.Session)
+		c.After()
+		return c, w.Result().Cookies()
+	}
+
+	// Untouched sessions are not written.
+	if _, cs := do(nil, func(map[string]string) {}); len(cs) != 0 {
+		t.Errorf("No cookies are expected for unchanged session, got %v.", cs)
+	}
+	_, cs := do(nil, func(m map[string]string) { m["user"] = "1" })
+	if len(cs) != 1 {
+		t.Fatalf("Cookie of changed session is expected, got %v.", cs)
+	}
+	if _, cs := do(cs[0], func(m map[string]string) { m["user"] = "1" }); len(cs) != 0 {
+		t.Errorf("No cookies are expected if values are the same, got %v.", cs)
+	}
+	*rolling = true
+	if _, cs := do(cs[0], func(map[string]string) {}); len(cs) != 1 {
+		t.Errorf("Cookie is expected to be written if rolling is on, got %v.", cs)
+	}
+	*rolling = false
+
+	// Tampered cookies are counted and removed.
+	n := DecodeErrors()
+	bad := &http.Cookie{Name: *cookieName, Value: cs[0].Value + "x"}
+	c, cs := do(bad, func(map[string]string) {})
+	if len(c.Session) != 0 || DecodeErrors() != n+1 {
+		t.Errorf("Tampered cookie is expected to be rejected and counted, got %v, %d.", c.Session, DecodeErrors()-n)
+	}
+	if len(cs) != 1 || cs[0].MaxAge >= 0 {
+		t.Errorf("Tampered cookie is expected to be removed, got %v.", cs)
+	}
+}
+
+func TestSession_Values(t *testing.T) {
+	s := Session{}
+	s.SetInt("n", 42)
+	s.SetBool("b", true)
+	now := time.Now()
+	s.SetTime("t", now)
+	if err := s.SetJSON("j", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, ok := s.Int("n"); !ok || n != 42 {
+		t.Errorf("Expected 42, got %d, %v.", n, ok)
+	}
+	if b, ok := s.Bool("b"); !ok || !b {
+		t.Errorf("Expected true, got %v, %v.", b, ok)
+	}
+	if tm, ok := s.Time("t"); !ok || !tm.Equal(now) {
+		t.Errorf("Expected %v, got %v, %v.", now, tm, ok)
+	}
+	var j []string
+	if err := s.JSON("j", &j); err != nil || len(j) != 2 || j[1] != "b" {
+		t.Errorf("Expected [a b], got %v, %v.", j, err)
+	}
+	if _, ok := s.Int("b"); ok {
+		t.Error("Bool is not expected to be returned as an int.")
+	}
+	if _, ok := s.Time("missing"); ok {
+		t.Error("Missing value is not expected to be found.")
+	}
+	if err := s.JSON("missing", &j); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v.", err)
+	}
+}
+
+func TestSessions_Flash(t *testing.T) {
+	Init(url.Values{})
+	do := func(cookie *http.Cookie, f func(c *Sessions)) (*Sessions, *http.Cookie) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		c := &Sessions{Request: r, Response: w}
+		c.Before()
+		f(c)
+		c.After()
+		if cs := w.Result().Cookies(); len(cs) > 0 {
+			cookie = cs[0]
+		}
+		return c, cookie
+	}
+
+	c, cookie := do(nil, func(c *Sessions) {
+		c.SetFlash("success", "Saved.")
+	})
+	if len(c.Flash) != 0 {
+		t.Errorf("Flash is not expected during the request it was set, got %v.", c.Flash)
+	}
+	first := cookie
+	c, cookie = do(cookie, func(*Sessions) {})
+	if c.Flash["success"] != "Saved." {
+		t.Errorf("Flash is expected during the next request, got %v.", c.Flash)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.AddCookie(first)
+	var f Flash
+	Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f = FlashFromRequest(r)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if f["success"] != "Saved." {
+		t.Errorf("Flash is expected in the context of the request, got %v.", f)
+	}
+	if FlashFromRequest(r) != nil {
+		t.Error("Request is not expected to be modified.")
+	}
+	c, _ = do(cookie, func(*Sessions) {})
+	if len(c.Flash) != 0 {
+		t.Errorf("Flash is expected to be shown once, got %v.", c.Flash)
+	}
+}
+
+func TestCheckCookie(t *testing.T) {
+	defer func() {
+		*cookieName, *cookieSecure, *cookieDomain, *cookiePath, *cookieSite = "_Session", false, "", "/", "lax"
+		Init(url.Values{})
+	}()
+	for i, v := range []struct {
+		name, domain, path, site string
+		secure, ok               bool
+	}{
+		{"_Session", "", "/", "lax", false, true},
+		{"_Session", "", "/", "none", false, false},
+		{"_Session", "", "/", "none", true, true},
+		{"__Secure-Session", "example.com", "/app", "strict", false, false},
+		{"__Secure-Session", "example.com", "/app", "strict", true, true},
+		{"__Host-Session", "", "/", "lax", true, true},
+		{"__Host-Session", "example.com", "/", "lax", true, false},
+		{"__Host-Session", "", "/app", "lax", true, false},
+	} {
+		*cookieName, *cookieDomain, *cookiePath, *cookieSite, *cookieSecure = v.name, v.domain, v.path, v.site, v.secure
+		sameSite = map[string]http.SameSite{
+			"lax": http.SameSiteLaxMode, "strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode,
+		}[v.site]
+		if err := checkCookie(); (err == nil) != v.ok {
+			t.Errorf("Test %d: expected ok %v, got %v.", i, v.ok, err)
+		}
+	}
+}
+
+func TestSessions_Destroy(t *testing.T) {
+	Default = NewMemoryStore(time.Hour)
+	defer func() {
+		Default = nil
+		*cookiePath = "/"
+	}()
+	*cookiePath = "/app"
+	Init(url.Values{})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	c := &Sessions{Request: r, Response: w}
+	c.Before()
+	c.Session["user"] = "1"
+	c.After()
+	cookie := w.Result().Cookies()[0]
+	if cookie.Path != "/app" || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Cookie attributes are expected to be set, got %v.", cookie)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	c = &Sessions{Request: r, Response: w}
+	c.Before()
+	if err := c.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	c.After()
+	if cs := w.Result().Cookies(); len(cs) != 1 || cs[0].MaxAge >= 0 || cs[0].Path != "/app" {
+		t.Errorf("Cookie is expected to be expired, got %v.", cs)
+	}
+	if _, err := Default.Load(cookie.Value); err != ErrNotFound {
+		t.Errorf("Destroyed session is not expected to be found, got %v.", err)
+	}
+}
+
+func TestSessions_Touch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs, err := NewFileStore(dir, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		Default = nil
+	}()
+
+	for _, st := range []Store{NewMemoryStore(100 * time.Millisecond), fs} {
+		Default = st
+		Init(url.Values{})
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		c := &Sessions{Request: r, Response: w}
+		c.Before()
+		c.Session["user"] = "1"
+		c.After()
+		cookie := w.Result().Cookies()[0]
+
+		// Read-only requests keep the session alive
+		// for longer than the TTL.
+		for i := 0; i < 4; i++ {
+			time.Sleep(40 * time.Millisecond)
+			w = httptest.NewRecorder()
+			r, _ = http.NewRequest("GET", "/", nil)
+			r.AddCookie(cookie)
+			c = &Sessions{Request: r, Response: w}
+			c.Before()
+			c.After()
+			if c.Session["user"] != "1" {
+				t.Fatalf("%T: active session is not expected to expire, got %v.", st, c.Session)
+			}
+			if cs := w.Result().Cookies(); len(cs) != 0 {
+				t.Errorf("%T: no cookies are expected for unchanged session, got %v.", st, cs)
+			}
+		}
+	}
+}
+
+func TestFileStore_TouchSave(t *testing.T) {
+	Init(url.Values{})
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs, err := NewFileStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests that do not change the session must not
+	// overwrite data saved by concurrent ones.
+	for i := 0; i < 20; i++ {
+		v, err := fs.Save("", map[string]string{"user": ""})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for j := 0; j < 10; j++ {
+				fs.Touch(v)
+			}
+		}()
+		if _, err := fs.Save(v, map[string]string{"user": "1"}); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		if m, err := fs.Load(v); err != nil || m["user"] != "1" {
+			t.Fatalf("Saved data is expected to be kept, got %v, %v.", m, err)
+		}
+	}
+}
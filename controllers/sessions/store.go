@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNotFound is returned by stores if there is no such session.
+var ErrNotFound = errors.New("session not found")
+
+// Store loads and saves sessions. Values that are passed to and returned
+// by a store are values of session cookies. A store may keep session data
+// in the cookie itself or on the server with just a signed session ID
+// in the cookie.
+type Store interface {
+	// Load returns data of the session the cookie value refers to.
+	Load(value string) (map[string]string, error)
+
+	// Save saves data of the session the cookie value refers to
+	// and returns a new value of the cookie. The value is empty
+	// if the session is new.
+	Save(value string, data map[string]string) (string, error)
+
+	// Delete removes the session the cookie value refers to.
+	Delete(value string) error
+}
+
+// Toucher is implemented by stores that keep sessions on the server
+// and expire them after a period of inactivity. Touch is called
+// for sessions that were used but not changed by the request.
+type Toucher interface {
+	// Touch extends the lifetime of the session
+	// the cookie value refers to.
+	Touch(value string) error
+}
+
+// CookieStore is a Store that keeps data of sessions
+// in signed cookies. Their size is limited to about 4KB.
+type CookieStore struct{}
+
+// Load decodes session data from the cookie value.
+func (CookieStore) Load(value string) (map[string]string, error) {
+	m := map[string]string{}
+	if err := decode(value, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save encodes session data to a new cookie value.
+func (CookieStore) Save(value string, data map[string]string) (string, error) {
+	return encode(data)
+}
+
+// Delete does nothing as data is stored on the client.
+func (CookieStore) Delete(value string) error {
+	return nil
+}
+
+// decodeID returns a session ID from the signed cookie value.
+func decodeID(value string) (string, error) {
+	var id string
+	if err := decode(value, &id); err != nil {
+		return "", err
+	}
+	if !validID(id) {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
+
+// encodeID returns a signed cookie value with the session ID.
+func encodeID(id string) (string, error) {
+	return encode(id)
+}
+
+// newID generates a new random session ID.
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validID returns true if the ID was generated by newID.
+// IDs are used as file names, so they are checked strictly.
+func validID(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// copyMap returns a copy of the session data.
+func copyMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package sessions
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Session is data of a session. Values are strings, use the typed
+// getters and setters to store other types.
+type Session map[string]string
+
+// Int returns the value of the key as an int.
+// It returns false if there is no such value or it is not an int.
+func (s Session) Int(key string) (int, bool) {
+	v, ok := s[key]
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(v)
+	return i, err == nil
+}
+
+// SetInt sets the value of the key to the int.
+func (s Session) SetInt(key string, v int) {
+	s[key] = strconv.Itoa(v)
+}
+
+// Bool returns the value of the key as a bool.
+// It returns false if there is no such value or it is not a bool.
+func (s Session) Bool(key string) (bool, bool) {
+	v, ok := s[key]
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(v)
+	return b, err == nil
+}
+
+// SetBool sets the value of the key to the bool.
+func (s Session) SetBool(key string, v bool) {
+	s[key] = strconv.FormatBool(v)
+}
+
+// Time returns the value of the key as a time.
+// It returns false if there is no such value or it is not a time.
+func (s Session) Time(key string) (time.Time, bool) {
+	v, ok := s[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	return t, err == nil
+}
+
+// SetTime sets the value of the key to the time.
+// It is stored in RFC 3339 format.
+func (s Session) SetTime(key string, v time.Time) {
+	s[key] = v.Format(time.RFC3339Nano)
+}
+
+// JSON decodes the JSON encoded value of the key into dst.
+// It returns ErrNotFound if there is no such value.
+func (s Session) JSON(key string, dst interface{}) error {
+	v, ok := s[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal([]byte(v), dst)
+}
+
+// SetJSON sets the value of the key to v encoded as JSON.
+func (s Session) SetJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s[key] = string(b)
+	return nil
+}
@@ -0,0 +1,90 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	pathpkg "path"
+	"strings"
+)
+
+// hashLen is a number of hex characters of content hashes
+// that are added to names of fingerprinted files.
+const hashLen = 8
+
+// Manifest maps paths of static assets to their fingerprinted versions
+// that include hashes of the files' content, e.g. "css/app.css"
+// to "css/app.3f9a1c2b.css". Fingerprinted paths change every time
+// the content is changed, so they can be cached forever.
+type Manifest struct {
+	paths map[string]string // Path -> fingerprinted path.
+	files map[string]string // Fingerprinted path -> path.
+}
+
+// NewManifest scans the file system and computes
+// fingerprinted paths of all its files. If the file system's
+// root directory does not exist, the manifest is empty.
+func NewManifest(fsys fs.FS) (*Manifest, error) {
+	m := &Manifest{
+		paths: map[string]string{},
+		files: map[string]string{},
+	}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil && p == "." && errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		h, err := hash(fsys, p)
+		if err != nil {
+			return err
+		}
+		ext := pathpkg.Ext(p)
+		fp := strings.TrimSuffix(p, ext) + "." + h + ext
+		m.paths[p] = fp
+		m.files[fp] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Path returns a fingerprinted version of the path or the path as is
+// if there is no such file in the manifest.
+func (m *Manifest) Path(p string) string {
+	if m == nil {
+		return p
+	}
+	if fp, ok := m.paths[strings.TrimPrefix(p, "/")]; ok {
+		return fp
+	}
+	return p
+}
+
+// Lookup returns the original path of the fingerprinted one.
+func (m *Manifest) Lookup(fp string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	p, ok := m.files[strings.TrimPrefix(fp, "/")]
+	return p, ok
+}
+
+// hash returns a shortened hex SHA-256 hash of the file's content.
+func hash(fsys fs.FS, p string) (string, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLen], nil
+}
@@ -0,0 +1,51 @@
+package static
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strings"
+)
+
+// CacheRule is a value of Cache-Control header for assets
+// that match the pattern. Patterns starting with a dot match
+// extensions, e.g. ".css", patterns without slashes match base names,
+// e.g. "*.min.js", and others match paths relative to the root
+// directory, e.g. "images/*".
+type CacheRule struct {
+	Pattern string
+	Value   string
+}
+
+// match returns true if the path relative to the root matches the rule.
+func (c CacheRule) match(p string) bool {
+	switch {
+	case strings.HasPrefix(c.Pattern, "."):
+		return strings.EqualFold(pathpkg.Ext(p), c.Pattern)
+	case !strings.Contains(c.Pattern, "/"):
+		p = pathpkg.Base(p)
+	}
+	ok, _ := pathpkg.Match(c.Pattern, p)
+	return ok
+}
+
+// parseCacheRules parses rules in the format of "static:cache.rules" flag:
+//
+//	.woff2=public, max-age=2592000; images/*=no-cache
+func parseCacheRules(s string) ([]CacheRule, error) {
+	var res []CacheRule
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		i := strings.IndexByte(v, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf(`incorrect cache rule "%s"`, v)
+		}
+		r := CacheRule{Pattern: strings.TrimSpace(v[:i]), Value: strings.TrimSpace(v[i+1:])}
+		if _, err := pathpkg.Match(r.Pattern, ""); err != nil {
+			return nil, fmt.Errorf(`incorrect pattern of cache rule "%s": %v`, v, err)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
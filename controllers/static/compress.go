@@ -0,0 +1,183 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encodings are content encodings of precompressed siblings
+// of static assets in order of preference.
+var encodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// minGzipSize is the minimum size of files that are compressed on the fly.
+const minGzipSize = 256
+
+// gzipCache stores files that were compressed on the fly.
+type gzipCache struct {
+	sync.Mutex
+	size    int64
+	entries map[string]*gzipped
+}
+
+// gzipped is a file compressed on the fly.
+type gzipped struct {
+	mod  time.Time
+	data []byte
+}
+
+// serveEncoded serves a precompressed sibling of the requested file,
+// e.g. "app.css.gz" for "app.css", if the client accepts its encoding.
+// If there are no siblings and Gzip is on, text files are compressed
+// on the fly. It returns false if the file must be served as is.
+func (t *Root) serveEncoded(w http.ResponseWriter, r *http.Request, fsys http.FileSystem) bool {
+	p := r.URL.Path
+	if strings.HasSuffix(p, "/") || r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	ctype := mime.TypeByExtension(pathpkg.Ext(p))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	// Look for the precompressed siblings.
+	if t.Precompressed {
+		found := false
+		for _, e := range encodings {
+			f, err := fsys.Open(p + e.ext)
+			if err != nil {
+				continue
+			}
+			if !found {
+				w.Header().Add("Vary", "Accept-Encoding")
+				found = true
+			}
+			d, err := f.Stat()
+			if err != nil || d.IsDir() || !accepts(r, e.name) {
+				f.Close()
+				continue
+			}
+			w.Header().Set("Content-Type", ctype)
+			w.Header().Set("Content-Encoding", e.name)
+			http.ServeContent(w, r, p, d.ModTime(), f)
+			f.Close()
+			return true
+		}
+		if found {
+			return false
+		}
+	}
+
+	// Compress text files on the fly.
+	if !t.Gzip || t.gz == nil || !compressible(ctype) {
+		return false
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !accepts(r, "gzip") {
+		return false
+	}
+	f, err := fsys.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	d, err := f.Stat()
+	if err != nil || d.IsDir() || d.Size() < minGzipSize {
+		return false
+	}
+	data, err := t.gz.compress(p, d.ModTime(), f, t.GzipCacheMB<<20)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Encoding", "gzip")
+	http.ServeContent(w, r, p, d.ModTime(), bytes.NewReader(data))
+	return true
+}
+
+// compress returns the gzipped content of the file using
+// the cache if the file was not modified since it was compressed.
+// Files are not cached if the size of the cache exceeds max.
+func (c *gzipCache) compress(p string, mod time.Time, f io.Reader, max int64) ([]byte, error) {
+	c.Lock()
+	e, ok := c.entries[p]
+	c.Unlock()
+	if ok && e.mod.Equal(mod) {
+		return e.data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, f); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+
+	// Cache the result unless the cache is full.
+	c.Lock()
+	if old, ok := c.entries[p]; ok {
+		c.size -= int64(len(old.data))
+		delete(c.entries, p)
+	}
+	if c.size+int64(len(data)) <= max {
+		c.entries[p] = &gzipped{mod: mod, data: data}
+		c.size += int64(len(data))
+	}
+	c.Unlock()
+	return data, nil
+}
+
+// compressible returns true if files of the content type
+// are worth compressing.
+func compressible(ctype string) bool {
+	t, _, _ := mime.ParseMediaType(ctype)
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		strings.HasSuffix(t, "+xml"),
+		strings.HasSuffix(t, "+json"),
+		t == "application/javascript",
+		t == "application/json",
+		t == "application/xml",
+		t == "application/wasm":
+		return true
+	}
+	return false
+}
+
+// accepts returns true if Accept-Encoding header
+// of the request allows the encoding.
+func accepts(r *http.Request, enc string) bool {
+	for _, h := range r.Header["Accept-Encoding"] {
+		for _, v := range strings.Split(h, ",") {
+			ps := strings.Split(v, ";")
+			if !strings.EqualFold(strings.TrimSpace(ps[0]), enc) {
+				continue
+			}
+			for _, p := range ps[1:] {
+				if p = strings.TrimSpace(p); strings.HasPrefix(p, "q=") {
+					if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q == 0 {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package static
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+// immutable is a value of Cache-Control header of fingerprinted assets.
+const immutable = "public, max-age=31536000, immutable"
+
+// Root is a directory or file system with static assets
+// that is served with its own configuration.
+type Root struct {
+	// Prefix is a prefix of URLs of the assets, e.g. "/uploads".
+	Prefix string
+
+	// Dir is a path to the directory with the assets.
+	// It is used if FS is nil.
+	Dir string
+
+	// FS is a file system with the assets.
+	FS fs.FS
+
+	// Fingerprint makes the assets be available at paths including
+	// hashes of their content that are cached forever.
+	Fingerprint bool
+
+	// MaxAge is how long assets requested by their regular paths
+	// are cached unless one of CacheRules matches.
+	MaxAge time.Duration
+
+	// CacheRules are rules of Cache-Control headers of assets
+	// requested by their regular paths. The first matching rule is used.
+	CacheRules []CacheRule
+
+	// Precompressed makes .br, .zst, and .gz siblings of files be served
+	// to clients accepting them.
+	Precompressed bool
+
+	// Gzip makes text files without .gz siblings be compressed on the fly.
+	// Up to GzipCacheMB megabytes of the compressed files are kept in memory.
+	Gzip        bool
+	GzipCacheMB int64
+
+	// Listing makes directories without index.html be listed.
+	Listing bool
+
+	// Dotfiles makes files and directories starting with a dot be served.
+	Dotfiles bool
+
+	// Fallback is a file that is served for missing paths without
+	// extensions, e.g. "index.html" of a single page app.
+	Fallback string
+
+	// NotFound is used for responding to requests of missing files.
+	// If it is nil, the NotFound package variable is used.
+	NotFound http.HandlerFunc
+
+	// Assets is a manifest of the assets that is built by Load
+	// if Fingerprint is on.
+	Assets *Manifest
+
+	gz *gzipCache // Files compressed on the fly.
+}
+
+// Load prepares the root for serving and builds
+// a manifest of its assets if Fingerprint is on.
+func (t *Root) Load() error {
+	t.gz = &gzipCache{entries: map[string]*gzipped{}}
+	t.Assets = nil
+	if !t.Fingerprint {
+		return nil
+	}
+	m, err := NewManifest(t.rootFS())
+	if err != nil {
+		return err
+	}
+	t.Assets = m
+	return nil
+}
+
+// URL returns a URL of the asset with the path relative to the root,
+// e.g. "css/app.css". The fingerprinted path is used if it is known.
+func (t *Root) URL(p string) string {
+	return strings.TrimSuffix(t.Prefix, "/") + "/" + strings.TrimPrefix(t.Assets.Path(p), "/")
+}
+
+// ServeHTTP serves the asset with the request's path relative to the root,
+// so the root may be used with http.StripPrefix or mounted on a router.
+func (t *Root) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.serve(w, r, r.URL.Path)
+}
+
+// serve serves the asset with the path relative to the root.
+func (t *Root) serve(w http.ResponseWriter, r *http.Request, p string) {
+	p, ok := clean(p)
+	if !ok {
+		t.notFound(w, r)
+		return
+	}
+	cc := ""
+	if op, ok := t.Assets.Lookup(p); ok {
+		cc, p = immutable, "/"+op
+	} else {
+		cc = t.cacheControl(p)
+	}
+
+	// Requests are passed to the handlers with the path
+	// relative to the root.
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+
+	fsys := t.root()
+	if !t.exists(w, r2, fsys) {
+		return
+	}
+	if cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+	if t.serveEncoded(w, r2, fsys) {
+		return
+	}
+	http.FileServer(fsys).ServeHTTP(w, r2)
+}
+
+// clean returns the path with a leading slash. Paths with ".." elements,
+// backslashes, or NUL characters are rejected to prevent access
+// to files outside of the root.
+func clean(p string) (string, bool) {
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	for _, e := range strings.Split(p, "/") {
+		if e == ".." {
+			return "", false
+		}
+	}
+	c := pathpkg.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && c != "/" {
+		c += "/"
+	}
+	return c, true
+}
+
+// exists checks whether the requested file can be served.
+// If it cannot, the fallback file or NotFound is served and false
+// is returned.
+func (t *Root) exists(w http.ResponseWriter, r *http.Request, fsys http.FileSystem) bool {
+	p := pathpkg.Clean(r.URL.Path)
+	if !t.Dotfiles && hidden(p) {
+		t.notFound(w, r)
+		return false
+	}
+
+	f, err := fsys.Open(p)
+	if err != nil {
+		if t.Fallback != "" && pathpkg.Ext(p) == "" && t.serveFallback(w, r, fsys) {
+			return false
+		}
+		t.notFound(w, r)
+		return false
+	}
+	defer f.Close()
+	d, err := f.Stat()
+	if err != nil {
+		t.notFound(w, r)
+		return false
+	}
+
+	// Directories without index files are not listed unless
+	// it is allowed explicitly.
+	if d.IsDir() && !t.Listing {
+		i, err := fsys.Open(pathpkg.Join(p, "index.html"))
+		if err != nil {
+			t.notFound(w, r)
+			return false
+		}
+		i.Close()
+	}
+	return true
+}
+
+// serveFallback serves the Fallback file. Its responses
+// are not cached as they depend on the request's path.
+func (t *Root) serveFallback(w http.ResponseWriter, r *http.Request, fsys http.FileSystem) bool {
+	f, err := fsys.Open(pathpkg.Clean("/" + t.Fallback))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	d, err := f.Stat()
+	if err != nil || d.IsDir() {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, d.Name(), d.ModTime(), f)
+	return true
+}
+
+// notFound responds to requests of missing files.
+func (t *Root) notFound(w http.ResponseWriter, r *http.Request) {
+	if t.NotFound != nil {
+		t.NotFound(w, r)
+		return
+	}
+	NotFound(w, r)
+}
+
+// cacheControl returns a value of Cache-Control header for the asset
+// with the path that is requested by its regular path.
+func (t *Root) cacheControl(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	for _, r := range t.CacheRules {
+		if r.match(p) {
+			return r.Value
+		}
+	}
+	if t.MaxAge > 0 {
+		return fmt.Sprintf("public, max-age=%d", int(t.MaxAge.Seconds()))
+	}
+	return ""
+}
+
+// hidden returns true if any element of the path starts with a dot.
+// ".well-known" directory is not hidden.
+func hidden(p string) bool {
+	for _, e := range strings.Split(p, "/") {
+		if strings.HasPrefix(e, ".") && e != ".well-known" {
+			return true
+		}
+	}
+	return false
+}
+
+// root returns a file system with static assets.
+func (t *Root) root() http.FileSystem {
+	if t.FS != nil {
+		return http.FS(t.FS)
+	}
+	return http.Dir(t.Dir)
+}
+
+// rootFS returns the same file system as root as fs.FS.
+func (t *Root) rootFS() fs.FS {
+	if t.FS != nil {
+		return t.FS
+	}
+	return os.DirFS(t.Dir)
+}
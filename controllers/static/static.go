@@ -1,23 +1,147 @@
+// Package static implements serving of static assets.
+//
+// Assets are available at their regular paths, e.g. "/css/app.css",
+// that are cached for a short time, and at fingerprinted paths that
+// include hashes of their content, e.g. "/css/app.3f9a1c2b.css",
+// that are cached forever. Register "asset" template function
+// to get URLs of the latter:
+//
+//	static.RegisterFuncs(templates.Funcs)
+//	funcs.AssetURL = static.URL // If url.Asset of the funcs package is used.
+//
+//	<link rel="stylesheet" href="{%asset "css/app.css"%}">
+//
+// The Default root is configured using "static:" flags. Additional
+// roots, e.g. with user uploads, may be added to Roots and chosen
+// by controllers or mounted on a router as they are http.Handlers:
+//
+//	static.Roots["uploads"] = &static.Root{Prefix: "/uploads", Dir: "./uploads"}
+//	router.Mount("/uploads", static.Roots["uploads"])
+//
+// Missing files and hidden dotfiles are responded to using
+// http.NotFound, not the NotFound handler of your router, as
+// the package does not know the router. To show the app's custom
+// 404 page, set NotFound to the router's handler:
+//
+//	static.NotFound = router.NotFound
 package static
 
 import (
 	"flag"
+	"html/template"
+	"io/fs"
+	"log"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 var (
 	path   = flag.String("static:root.directory", "./static", "path to the directory with static assets")
 	prefix = flag.String("static:path.prefix", "/", "a prefix that's added to static assets' paths")
+
+	fingerprint = flag.Bool("static:fingerprint", true, "serve assets at paths including hashes of their content")
+	maxAge      = flag.Duration("static:cache.maxage", 5*time.Minute, "how long assets requested by regular paths are cached")
+	cacheRules  = flag.String("static:cache.rules", "", "semicolon separated Cache-Control rules, e.g. \".woff2=public, max-age=2592000; images/*=no-cache\"")
+
+	precompressed = flag.Bool("static:precompressed", true, "serve .br, .zst, and .gz siblings of files to clients accepting them")
+	gzipOn        = flag.Bool("static:gzip", false, "compress text files without .gz siblings on the fly")
+	gzipCacheMB   = flag.Int64("static:gzip.cache", 16, "number of MB of files compressed on the fly to keep in memory")
+
+	listing  = flag.Bool("static:dir.listing", false, "list files of directories without index.html")
+	hideDot  = flag.Bool("static:hide.dotfiles", true, "respond with 404 to requests of files and directories starting with a dot")
+	fallback = flag.String("static:fallback", "", "file served for missing paths without extensions, e.g. index.html of single page apps")
+
+	// FS is a file system static assets of the Default root are served
+	// from, e.g. embed.FS, fstest.MapFS, or zip.Reader. If it is nil,
+	// the directory specified by "static:root.directory" flag is used.
+	FS fs.FS
+
+	// Default is a root that is configured using "static:" flags
+	// and the FS package variable.
+	Default = &Root{}
+
+	// Roots are additional roots by their names. They are loaded by Init.
+	Roots = map[string]*Root{}
+
+	// NotFound is used for responding to requests of missing files
+	// of roots without their own NotFound handlers. It is http.NotFound
+	// by default, so set it, Root.NotFound, or Static.NotFound to
+	// the handler of your router to use its custom 404 page.
+	NotFound http.HandlerFunc = http.NotFound
 )
 
 // Static is a controller that brings static
 // assets' serving functionality to your app.
 type Static struct {
+	// Root is a root the assets are served from.
+	// If not specified explicitly, Default will be used.
+	Root *Root
+
+	// NotFound is used for responding to requests of missing files
+	// instead of the NotFound handler of the Root.
+	NotFound http.HandlerFunc
 }
 
-// Serve is a wrapper around Go's standard FileServer
-// and StripPrefix HTTP handlers.
+// Serve serves the asset with the path from "filepath" route parameter.
+// Paths with ".." elements are rejected. Fingerprinted paths
+// are served with immutable Cache-Control header.
 //@get /*filepath
 func (c *Static) Serve(filepath string) http.Handler {
-	return http.StripPrefix(*prefix, http.FileServer(http.Dir(*path)))
+	root := c.Root
+	if root == nil {
+		root = Default
+	}
+	if c.NotFound != nil {
+		r := *root
+		r.NotFound = c.NotFound
+		root = &r
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		root.serve(w, r, filepath)
+	})
+}
+
+// URL returns a URL of the asset of the Default root.
+// See Root.URL.
+func URL(p string) string {
+	return Default.URL(p)
+}
+
+// RegisterFuncs adds "asset" function that returns URLs
+// of the assets of the Default root to the function map of templates.
+func RegisterFuncs(m template.FuncMap) {
+	m["asset"] = URL
+}
+
+// Init is a function that is used for initialization of
+// Static controller. It configures the Default root using flags
+// and loads it and the Roots.
+func Init(url.Values) {
+	rs, err := parseCacheRules(*cacheRules)
+	if err != nil {
+		log.Panicf(`Cannot parse "static:cache.rules". Error: %v.`, err)
+	}
+	*Default = Root{
+		Prefix:        *prefix,
+		Dir:           *path,
+		FS:            FS,
+		Fingerprint:   *fingerprint,
+		MaxAge:        *maxAge,
+		CacheRules:    rs,
+		Precompressed: *precompressed,
+		Gzip:          *gzipOn,
+		GzipCacheMB:   *gzipCacheMB,
+		Listing:       *listing,
+		Dotfiles:      !*hideDot,
+		Fallback:      *fallback,
+	}
+	if err := Default.Load(); err != nil {
+		log.Panicf(`Cannot build a manifest of static assets. Error: %v.`, err)
+	}
+	for name, r := range Roots {
+		if err := r.Load(); err != nil {
+			log.Panicf(`Cannot build a manifest of static assets of "%s". Error: %v.`, name, err)
+		}
+	}
 }
@@ -0,0 +1,202 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStatic_Fingerprint(t *testing.T) {
+	FS = fstest.MapFS{
+		"css/app.css": {Data: []byte("body{}")},
+		"robots.txt":  {Data: []byte("User-agent: *")},
+	}
+	defer func() {
+		FS = nil
+	}()
+	Init(url.Values{})
+
+	u := URL("css/app.css")
+	if u == "/css/app.css" || URL("/robots.txt") == "/robots.txt" || URL("unknown.js") != "/unknown.js" {
+		t.Fatalf("Unexpected asset URLs: %s, %s, %s.", u, URL("/robots.txt"), URL("unknown.js"))
+	}
+	m := template.FuncMap{}
+	RegisterFuncs(m)
+	if f, ok := m["asset"].(func(string) string); !ok || f("css/app.css") != u {
+		t.Errorf(`"asset" template function is expected to be registered, got %v.`, m)
+	}
+
+	c := &Static{}
+	for _, v := range []struct {
+		path, body, cache string
+	}{
+		{u, "body{}", immutable},
+		{"/css/app.css", "body{}", "public, max-age=300"},
+		{"/css/app.00000000.css", "404 page not found\n", ""},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", v.path, nil)
+		c.Serve(r.URL.Path).ServeHTTP(w, r)
+		if w.Body.String() != v.body || w.Header().Get("Cache-Control") != v.cache {
+			t.Errorf(`"%s": expected "%s" and "%s", got "%s" and %v.`, v.path, v.body, v.cache, w.Body, w.Header())
+		}
+	}
+}
+
+func TestStatic_Encodings(t *testing.T) {
+	css := bytes.Repeat([]byte("body{}"), 100)
+	FS = fstest.MapFS{
+		"css/app.css":    {Data: css},
+		"css/app.css.gz": {Data: []byte("gz")},
+		"css/app.css.br": {Data: []byte("br")},
+		"js/app.js":      {Data: css},
+		"img/logo.png":   {Data: css},
+	}
+	*gzipOn = true
+	*cacheRules = ".png=public, max-age=86400; js/*=no-cache"
+	defer func() {
+		FS = nil
+		*gzipOn, *cacheRules = false, ""
+	}()
+	Init(url.Values{})
+
+	c := &Static{}
+	for _, v := range []struct {
+		path, accept, enc, body, cache string
+	}{
+		{"/css/app.css", "gzip, br", "br", "br", "public, max-age=300"},
+		{"/css/app.css", "gzip, br;q=0", "gzip", "gz", "public, max-age=300"},
+		{"/css/app.css", "", "", string(css), "public, max-age=300"},
+		{"/js/app.js", "gzip", "gzip", string(css), "no-cache"},
+		{"/js/app.js", "", "", string(css), "no-cache"},
+		{"/img/logo.png", "gzip", "", string(css), "public, max-age=86400"},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", v.path, nil)
+		r.Header.Set("Accept-Encoding", v.accept)
+		c.Serve(r.URL.Path).ServeHTTP(w, r)
+
+		body := w.Body.Bytes()
+		if v.enc == "gzip" && v.body != "gz" {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ = ioutil.ReadAll(zr)
+		}
+		if string(body) != v.body || w.Header().Get("Content-Encoding") != v.enc || w.Header().Get("Cache-Control") != v.cache {
+			t.Errorf(`"%s", "%s": unexpected response %v.`, v.path, v.accept, w.Header())
+		}
+		if ct := w.Header().Get("Content-Type"); v.path == "/css/app.css" && ct != "text/css; charset=utf-8" {
+			t.Errorf(`"%s": unexpected content type "%s".`, v.path, ct)
+		}
+		if vary := w.Header().Get("Vary"); (vary == "") != (v.path == "/img/logo.png") {
+			t.Errorf(`"%s": unexpected Vary header "%s".`, v.path, vary)
+		}
+	}
+}
+
+func TestParseCacheRules(t *testing.T) {
+	rs, err := parseCacheRules(".woff2=public, max-age=2592000; images/*=no-cache;")
+	if err != nil || len(rs) != 2 || rs[0].Value != "public, max-age=2592000" || rs[1].Pattern != "images/*" {
+		t.Errorf("Unexpected rules %v, %v.", rs, err)
+	}
+	for _, s := range []string{"no-cache", "[=no-cache"} {
+		if _, err := parseCacheRules(s); err == nil {
+			t.Errorf(`"%s": error expected.`, s)
+		}
+	}
+}
+
+func TestStatic_Fallback(t *testing.T) {
+	FS = fstest.MapFS{
+		"index.html":               {Data: []byte("app")},
+		"js/app.js":                {Data: []byte("js")},
+		".env":                     {Data: []byte("secret")},
+		".git/config":              {Data: []byte("secret")},
+		".well-known/security.txt": {Data: []byte("contact")},
+	}
+	*fallback = "index.html"
+	NotFound = func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "custom", http.StatusNotFound)
+	}
+	defer func() {
+		FS = nil
+		*fallback = ""
+		NotFound = http.NotFound
+	}()
+	Init(url.Values{})
+
+	c := &Static{}
+	for _, v := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/js/app.js", http.StatusOK, "js"},
+		{"/users/1/settings", http.StatusOK, "app"},
+		{"/js/missing.js", http.StatusNotFound, "custom\n"},
+		{"/js/", http.StatusNotFound, "custom\n"},
+		{"/.env", http.StatusNotFound, "custom\n"},
+		{"/.git/config", http.StatusNotFound, "custom\n"},
+		{"/js/../index.html", http.StatusNotFound, "custom\n"},
+		{"/.well-known/security.txt", http.StatusOK, "contact"},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", v.path, nil)
+		c.Serve(r.URL.Path).ServeHTTP(w, r)
+		if w.Code != v.status || w.Body.String() != v.body {
+			t.Errorf(`"%s": expected %d "%s", got %d "%s".`, v.path, v.status, v.body, w.Code, w.Body)
+		}
+	}
+}
+
+func TestStatic_Roots(t *testing.T) {
+	Roots["uploads"] = &Root{
+		Prefix: "/uploads",
+		FS: fstest.MapFS{
+			"a.txt": {Data: []byte("a")},
+		},
+		Fingerprint: true,
+		NotFound: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "uploads", http.StatusNotFound)
+		},
+	}
+	defer delete(Roots, "uploads")
+	Init(url.Values{})
+	root := Roots["uploads"]
+
+	u := root.URL("a.txt")
+	if !strings.HasPrefix(u, "/uploads/a.") {
+		t.Errorf("Unexpected URL %s.", u)
+	}
+
+	for _, v := range []struct {
+		path, body string
+		h          http.Handler
+	}{
+		{"/anything", "a", (&Static{Root: root}).Serve("a.txt")},
+		{"/anything", "a", (&Static{Root: root}).Serve("/" + strings.TrimPrefix(u, "/uploads/"))},
+		{"/anything", "uploads\n", (&Static{Root: root}).Serve("../a.txt")},
+		{"/anything", "uploads\n", (&Static{Root: root}).Serve("x/../a.txt")},
+		{"/anything", "uploads\n", (&Static{Root: root}).Serve("..\\a.txt")},
+		{"/uploads/a.txt", "a", http.StripPrefix("/uploads", root)},
+		{"/anything", "static\n", (&Static{Root: root, NotFound: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "static", http.StatusNotFound)
+		}}).Serve("b.txt")},
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", v.path, nil)
+		v.h.ServeHTTP(w, r)
+		if w.Body.String() != v.body {
+			t.Errorf(`"%s": expected "%s", got "%s".`, v.path, v.body, w.Body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a template that failed to parse.
+type ParseError struct {
+	Template string // Normalized relative path of the template, e.g. "App/Index.html".
+	File     string // Path to the file with the error.
+	Line     int    // Line of the error, 0 if unknown.
+	Column   int    // Column of the error, 0 if unknown.
+	Err      error  // The original error.
+
+	source []string // Lines of the file.
+}
+
+// Error is used to implement error interface.
+// The message is in "file:line:column: error" format.
+func (e *ParseError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %v", pos, e.Err)
+}
+
+// Errors is a list of errors that occurred when loading templates.
+type Errors []*ParseError
+
+// Error is used to implement error interface.
+// Every broken template is listed on a separate line.
+func (e Errors) Error() string {
+	ss := make([]string, len(e))
+	for i := range e {
+		ss[i] = "\t" + e[i].Error()
+	}
+	return fmt.Sprintf("%d template(s) failed to parse:\n%s", len(e), strings.Join(ss, "\n"))
+}
+
+// sortedErrors returns a list of the errors ordered by templates' paths.
+func sortedErrors(m map[string]*ParseError) Errors {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	errs := make(Errors, len(ks))
+	for i := range ks {
+		errs[i] = m[ks[i]]
+	}
+	return errs
+}
+
+// appendErrors joins lists of errors returned by Load.
+func appendErrors(err, e error) error {
+	errs, _ := err.(Errors)
+	return append(errs, e.(Errors)...)
+}
+
+// parseErrRe matches errors of text/template/parse package, e.g.:
+//
+//	template: Index.html:3: unexpected "}" in operand
+var parseErrRe = regexp.MustCompile(`^template: ([^:]+):(\d+): (.*)$`)
+
+// unexpectedRe matches a token the parser did not expect.
+var unexpectedRe = regexp.MustCompile(`unexpected "([^"]+)"`)
+
+// newParseError returns a description of the error of parsing
+// the file that is a part of the template. Src is the content
+// of the file, nil if it could not be read.
+func newParseError(relNorm, file string, src []byte, err error) *ParseError {
+	e := &ParseError{
+		Template: relNorm,
+		File:     file,
+		Err:      err,
+	}
+	if src != nil {
+		e.source = strings.Split(string(src), "\n")
+	}
+	m := parseErrRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return e
+	}
+	e.Line, _ = strconv.Atoi(m[2])
+	e.Err = fmt.Errorf("%s", m[3])
+
+	// The parser does not report columns, but if it names
+	// the unexpected token, look for it on the line.
+	if u := unexpectedRe.FindStringSubmatch(m[3]); u != nil && e.Line <= len(e.source) {
+		if i := strings.Index(e.source[e.Line-1], u[1]); i >= 0 {
+			e.Column = i + 1
+		}
+	}
+	return e
+}
+
+// errorPage is a template of the page that is shown in development
+// mode instead of templates that failed to parse.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template error</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f6f6f6; color: #222; }
+header { background: #c0392b; color: #fff; padding: 1em 2em; }
+header h1 { margin: 0; font-size: 1.4em; }
+main { padding: 1em 2em; }
+.pos { font-family: monospace; font-size: 1.1em; }
+.msg { font-size: 1.2em; margin: 0.5em 0 1em; }
+pre { background: #fff; border: 1px solid #ddd; padding: 0.5em 0; overflow: auto; }
+pre span { display: block; padding: 0 1em; }
+pre span.err { background: #fde2e0; }
+pre i { color: #999; font-style: normal; display: inline-block; width: 3em; }
+</style>
+</head>
+<body>
+<header><h1>Failed to parse template "{{.Template}}"</h1></header>
+<main>
+<div class="pos">{{.File}}{{if .Line}}:{{.Line}}{{if .Column}}:{{.Column}}{{end}}{{end}}</div>
+<div class="msg">{{.Err}}</div>
+{{if .Source}}<pre>{{range .Source}}<span{{if .Current}} class="err"{{end}}><i>{{.N}}</i>{{.Text}}</span>{{end}}</pre>{{end}}
+</main>
+</body>
+</html>
+`))
+
+// serveParseError renders a page describing the error.
+func serveParseError(w http.ResponseWriter, e *ParseError) {
+	type line struct {
+		N       int
+		Text    string
+		Current bool
+	}
+	var src []line
+	if e.Line > 0 {
+		ls := e.source
+		for n := e.Line - 3; n <= e.Line+3; n++ {
+			if n >= 1 && n <= len(ls) {
+				src = append(src, line{n, ls[n-1], n == e.Line})
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	errorPage.Execute(buf, struct {
+		*ParseError
+		Source []line
+	}{e, src})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(buf.Bytes())
+}
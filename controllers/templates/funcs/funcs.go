@@ -0,0 +1,293 @@
+// Package funcs is an optional pack of helper functions
+// for templates. Functions are grouped into namespaces,
+// every namespace is a template function returning a value
+// whose methods are the helpers, e.g.:
+//
+//	{% time.Format "Jan 2, 2006" .Created %}
+//	{% .Size | num.Bytes %}
+//	{% template "_button" coll.Dict "title" "Save" "primary" true %}
+//
+// Call Register before templates are loaded to make them available:
+//
+//	funcs.Register(templates.Funcs)
+package funcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Namespaces are names of the namespaces registered by Register.
+var Namespaces = []string{"time", "num", "str", "safe", "coll", "url"}
+
+// AssetURL is used by url.Asset to get a URL of a static asset
+// by its path. By default, the path is prefixed with "/".
+var AssetURL = func(path string) string {
+	return "/" + strings.TrimPrefix(path, "/")
+}
+
+// Register adds the namespaces to the function map.
+// Existing functions with the same names are overridden.
+func Register(m template.FuncMap) {
+	m["time"] = func() TimeFuncs { return TimeFuncs{} }
+	m["num"] = func() NumFuncs { return NumFuncs{} }
+	m["str"] = func() StrFuncs { return StrFuncs{} }
+	m["safe"] = func() SafeFuncs { return SafeFuncs{} }
+	m["coll"] = func() CollFuncs { return CollFuncs{} }
+	m["url"] = func() URLFuncs { return URLFuncs{} }
+}
+
+// TimeFuncs are helpers for dates and time, they are
+// available as "time" namespace.
+type TimeFuncs struct{}
+
+// Format formats the time using the layout, see time.Time.Format.
+func (TimeFuncs) Format(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// Now returns the current time.
+func (TimeFuncs) Now() time.Time {
+	return time.Now()
+}
+
+// Ago returns the time relative to the current one in a human
+// readable form, e.g. "5 minutes ago" or "in 2 days".
+func (TimeFuncs) Ago(t time.Time) string {
+	d := time.Since(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var n int64
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int64(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int64(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		n, unit = int64(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		n, unit = int64(d/(30*24*time.Hour)), "month"
+	default:
+		n, unit = int64(d/(365*24*time.Hour)), "year"
+	}
+	s := fmt.Sprintf("%d %s", n, unit)
+	if n != 1 {
+		s += "s"
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// NumFuncs are helpers for numbers, they are available
+// as "num" namespace.
+type NumFuncs struct{}
+
+// Format formats the number with the number of decimals
+// and comma separated thousands, e.g. 1234.5 with 2 decimals
+// is formatted as "1,234.50".
+func (NumFuncs) Format(decimals int, v interface{}) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	var b []byte
+	for i := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b = append(b, ',')
+		}
+		b = append(b, intPart[i])
+	}
+	if f < 0 {
+		b = append([]byte{'-'}, b...)
+	}
+	return string(b) + frac, nil
+}
+
+// Bytes formats the size in bytes in a human readable form
+// using binary units, e.g. 1536 is formatted as "1.5 KB".
+func (NumFuncs) Bytes(v interface{}) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+	i := 0
+	for math.Abs(f) >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", int64(f), units[i]), nil
+	}
+	s := strconv.FormatFloat(f, 'f', 1, 64)
+	return strings.TrimSuffix(s, ".0") + " " + units[i], nil
+}
+
+// StrFuncs are helpers for strings, they are available
+// as "str" namespace.
+type StrFuncs struct{}
+
+// Truncate returns the first n characters of the string followed
+// by an ellipsis if the string is longer than n characters.
+func (StrFuncs) Truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// Plural returns the singular form if the number is 1
+// and the plural form otherwise, e.g.:
+//
+//	{% .Count %} {% str.Plural .Count "item" "items" %}
+func (StrFuncs) Plural(n interface{}, singular, plural string) (string, error) {
+	f, err := toFloat(n)
+	if err != nil {
+		return "", err
+	}
+	if f == 1 {
+		return singular, nil
+	}
+	return plural, nil
+}
+
+// Default returns the value if it is not empty and def otherwise.
+// Empty values are nil, false, 0, and empty strings, arrays,
+// slices, and maps.
+//
+//	{% .Name | str.Default "Anonymous" %}
+func (StrFuncs) Default(def, v interface{}) interface{} {
+	if empty(v) {
+		return def
+	}
+	return v
+}
+
+// SafeFuncs mark strings as safe for inclusion in specific contexts
+// of HTML documents, so they are not escaped. They are available
+// as "safe" namespace. Never use them with untrusted input.
+type SafeFuncs struct{}
+
+// HTML marks the string as a safe HTML fragment.
+func (SafeFuncs) HTML(s string) template.HTML {
+	return template.HTML(s)
+}
+
+// Attr marks the string as a safe HTML attribute, e.g. `dir="ltr"`.
+func (SafeFuncs) Attr(s string) template.HTMLAttr {
+	return template.HTMLAttr(s)
+}
+
+// URL marks the string as a safe URL.
+func (SafeFuncs) URL(s string) template.URL {
+	return template.URL(s)
+}
+
+// JS marks the string as a safe JavaScript expression.
+func (SafeFuncs) JS(s string) template.JS {
+	return template.JS(s)
+}
+
+// CSS marks the string as safe CSS.
+func (SafeFuncs) CSS(s string) template.CSS {
+	return template.CSS(s)
+}
+
+// JSON encodes the value as JSON for embedding into inline scripts.
+// Characters that are special in HTML are escaped, e.g.:
+//
+//	<script>var user = {% safe.JSON .User %};</script>
+func (SafeFuncs) JSON(v interface{}) (template.JS, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(b), nil
+}
+
+// CollFuncs construct collections, they are available as
+// "coll" namespace. They are useful for passing several values
+// to element templates.
+type CollFuncs struct{}
+
+// Dict returns a map built from key - value pairs, e.g.:
+//
+//	{% template "button" coll.Dict "title" "Save" "primary" true %}
+func (CollFuncs) Dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("funcs: coll.Dict expects an even number of arguments")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		k, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("funcs: coll.Dict keys must be strings, got %T", pairs[i])
+		}
+		m[k] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// List returns a slice of the values.
+func (CollFuncs) List(vs ...interface{}) []interface{} {
+	return vs
+}
+
+// URLFuncs are helpers for URLs, they are available
+// as "url" namespace.
+type URLFuncs struct{}
+
+// Asset returns a URL of the static asset, see AssetURL.
+func (URLFuncs) Asset(path string) string {
+	return AssetURL(path)
+}
+
+// toFloat converts a numeric value to float64.
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("funcs: expected a number, got %T", v)
+}
+
+// empty checks whether the value is empty.
+func empty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,65 @@
+package funcs
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	m := template.FuncMap{}
+	Register(m)
+	for _, n := range Namespaces {
+		if m[n] == nil {
+			t.Errorf(`Namespace "%s" is not registered.`, n)
+		}
+	}
+
+	for _, v := range []struct {
+		tpl  string
+		data interface{}
+		exp  string
+	}{
+		{`{{time.Format "2006-01-02" .}}`, time.Date(2015, 10, 21, 0, 0, 0, 0, time.UTC), "2015-10-21"},
+		{`{{time.Ago .}}`, time.Now().Add(-3 * time.Hour), "3 hours ago"},
+		{`{{time.Ago .}}`, time.Now().Add(25 * time.Hour), "in 1 day"},
+		{`{{time.Ago .}}`, time.Now(), "just now"},
+		{`{{num.Format 2 .}}`, -1234567.891, "-1,234,567.89"},
+		{`{{num.Format 0 .}}`, 999, "999"},
+		{`{{. | num.Bytes}}`, 1536, "1.5 KB"},
+		{`{{. | num.Bytes}}`, 512, "512 B"},
+		{`{{. | num.Bytes}}`, uint64(3 << 30), "3 GB"},
+		{`{{str.Truncate 5 .}}`, "Hello, world!", "Hello…"},
+		{`{{str.Truncate 5 .}}`, "Hi", "Hi"},
+		{`{{. | len | printf "%d"}} {{str.Plural (len .) "item" "items"}}`, []int{1}, "1 item"},
+		{`{{str.Plural . "item" "items"}}`, 2, "items"},
+		{`{{. | str.Default "Anonymous"}}`, "", "Anonymous"},
+		{`{{. | str.Default "Anonymous"}}`, "John", "John"},
+		{`{{safe.HTML .}}`, "<b>x</b>", "<b>x</b>"},
+		{`<a href="{{safe.URL .}}">`, "javascript:void(0)", `<a href="javascript:void%280%29">`},
+		{`<script>var x = {{safe.JSON .}};</script>`, map[string]string{"a": "</script>"}, `<script>var x = {"a":"\u003c/script\u003e"};</script>`},
+		{`{{with coll.Dict "a" 1 "b" .}}{{.a}}{{.b}}{{end}}`, "x", "1x"},
+		{`{{range coll.List 1 2 3}}{{.}}{{end}}`, nil, "123"},
+		{`{{url.Asset "css/app.css"}}`, nil, "/css/app.css"},
+	} {
+		tpl := template.Must(template.New("").Funcs(m).Parse(v.tpl))
+		buf := &bytes.Buffer{}
+		if err := tpl.Execute(buf, v.data); err != nil {
+			t.Errorf("%s: unexpected error %v.", v.tpl, err)
+			continue
+		}
+		if buf.String() != v.exp {
+			t.Errorf("%s: expected %q, got %q.", v.tpl, v.exp, buf.String())
+		}
+	}
+}
+
+func TestCollFuncs_Dict(t *testing.T) {
+	if _, err := (CollFuncs{}).Dict("a"); err == nil {
+		t.Errorf("Expected an error for odd number of arguments.")
+	}
+	if _, err := (CollFuncs{}).Dict(1, 2); err == nil {
+		t.Errorf("Expected an error for non-string keys.")
+	}
+}
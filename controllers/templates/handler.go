@@ -1,36 +1,156 @@
 package templates
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/goaltools/contrib/controllers/requestids"
 )
 
+// maxPooledBuf is the maximum capacity of buffers that are returned
+// to the pool, so occasional huge pages do not keep memory allocated.
+const maxPooledBuf = 1 << 20
+
+// bufPool is a pool of buffers templates are rendered to.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
 // Handler is a templates handler that implements http.Handler interface.
 type Handler struct {
+	set      *TemplateSet           // Set of templates the template belongs to.
 	context  map[string]interface{} // Variables to be passed to the template.
 	template string                 // Path to the template to be rendered.
 	status   int                    // Expected status code of the response.
+	stream   bool                   // Whether the template is executed directly to the response.
+	noLayout bool                   // Whether the template is rendered without its layout.
+	blocks   []string               // Blocks that are rendered instead of the layout.
+	fragment string                 // Block that is rendered instead of the layout if it is defined.
 }
 
 // Apply writes to response the result received from action.
+// The template is rendered to a buffer first, so if its execution
+// fails, an error page is shown instead of a truncated one.
 func (t *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Set status of the response.
 	if t.status == 0 {
 		t.status = http.StatusOK
 	}
-	w.Header().Set("Content-Type", *contType)
+	if t.set == nil {
+		t.set = Default
+	}
+	w.Header().Set("Content-Type", t.set.contentType(t.template))
+	for _, h := range append(partialHeaders(), splitList(*fullHdrs)...) {
+		w.Header().Add("Vary", h)
+	}
 
 	// If required template exists, execute it.
-	if tpl, ok := templates[t.template]; ok {
-		w.WriteHeader(t.status)
-		err := tpl.ExecuteTemplate(w, *layoutBl, t.context)
+	if tpl, ok := t.set.lookup(t.template); ok {
+		if t.stream {
+			w.WriteHeader(t.status)
+			err := t.set.execute(w, tpl, t.template, t.blocksOf(tpl), t.noLayout, t.context)
+			if err != nil {
+				logf(w, r, "%v", err)
+			}
+			return
+		}
+
+		buf := bufPool.Get().(*bytes.Buffer)
+		defer putBuf(buf)
+		buf.Reset()
+		err := t.set.execute(buf, tpl, t.template, t.blocksOf(tpl), t.noLayout, t.context)
 		if err != nil {
-			go Log.Println(err)
+			logf(w, r, "%v", err)
+			t.serveError(w, r, err)
+			return
 		}
+		write(w, t.status, buf)
+		return
+	}
+
+	// If the template failed to parse, describe the error in dev mode.
+	if e := t.set.parseError(t.template); e != nil && *devMode && *errPage {
+		serveParseError(w, e)
+		logf(w, r, "%v", e)
 		return
 	}
 
 	// Otherwise, show internal server error.
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Write([]byte("500 Internal Server Error"))
-	go Log.Printf(`Template "%s" does not exist.`, t.template)
+	logf(w, r, `Template "%s" does not exist.`, t.template)
+}
+
+// blocksOf returns blocks of the template that must be rendered
+// instead of its layout. The fragment is rendered only if
+// the template defines it, otherwise the full page is.
+func (t *Handler) blocksOf(tpl executor) []string {
+	if t.fragment != "" && defines(tpl, t.fragment) {
+		return []string{t.fragment}
+	}
+	return t.blocks
+}
+
+// serveError renders "errors/500.html" template after execution
+// of the requested template failed. In development mode the error
+// is passed to the template. If the error template cannot be rendered
+// either, a plain text message is written.
+func (t *Handler) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	name := fmt.Sprintf(*defTpl, *errsDir, http.StatusInternalServerError)
+	if tpl, ok := t.set.lookup(name); ok && name != t.template {
+		ctx := make(map[string]interface{}, len(t.context)+1)
+		for k, v := range t.context {
+			ctx[k] = v
+		}
+		if *devMode {
+			ctx["error"] = err
+		}
+
+		buf := bufPool.Get().(*bytes.Buffer)
+		defer putBuf(buf)
+		buf.Reset()
+		if e := t.set.execute(buf, tpl, name, t.blocksOf(tpl), t.noLayout, ctx); e == nil {
+			write(w, http.StatusInternalServerError, buf)
+			return
+		}
+	}
+
+	msg := "500 Internal Server Error"
+	if *devMode {
+		msg += "\n" + err.Error()
+	}
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+// write writes the buffer with the status code to the response.
+func write(w http.ResponseWriter, status int, buf *bytes.Buffer) {
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// putBuf returns the buffer to the pool unless it is too big.
+func putBuf(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuf {
+		bufPool.Put(buf)
+	}
+}
+
+// logf prints a message to the Log. The message is prefixed
+// with an ID of the request if there is one.
+func logf(w http.ResponseWriter, r *http.Request, format string, args ...interface{}) {
+	id := requestids.FromContext(r.Context())
+	if id == "" {
+		id = w.Header().Get(requestids.Header)
+	}
+	if id != "" {
+		Log.Printf("[%s] "+format, append([]interface{}{id}, args...)...)
+		return
+	}
+	Log.Printf(format, args...)
 }
@@ -1,25 +1,68 @@
 package templates
 
 import (
+	"fmt"
 	"html/template"
-	"os"
+	"io/fs"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	texttemplate "text/template"
+	"time"
 )
 
-// load gets a path with views and returns a map of
-// parsed templates that can be used by Render actions.
-func load(dir string) map[string]*template.Template {
-	m := map[string]*template.Template{}
+// load parses the templates and returns a map of
+// parsed templates that can be used by Render actions
+// and a map of templates that failed to parse.
+func (fl *files) load() (map[string]executor, map[string]*ParseError) {
+	m := map[string]executor{}
+	errs := map[string]*ParseError{}
 
-	dir = filepath.Clean(dir)
-	Log.Printf(`Parsing templates in "%s".`, dir)
+	// Print a list of element templates.
+	Log.Printf("View elements: %v", fl.els)
+
+	// Parse templates and register them.
+	for relNorm := range fl.tpls {
+		t, err := fl.parse(relNorm)
+		if err != nil {
+			errs[relNorm] = err
+			continue
+		}
+		m[relNorm] = t
+	}
+	return m, errs
+}
+
+// files stores information about template files of the views directory.
+// All paths are slash separated and relative to the root of the file system.
+type files struct {
+	set  *TemplateSet         // Set the files belong to.
+	fsys fs.FS                // File system with the views.
+	root string               // Path to the views directory on disk, empty if FS is used.
+	tpls map[string]string    // Normalized relative path -> path of regular templates.
+	els  []string             // Paths of element templates.
+	ls   layouts              // Directories that have layouts.
+	mods map[string]time.Time // Path -> modification time of all the files.
+	deps map[string][]string  // Normalized relative path -> layouts the template extends.
+	dir  *regexp.Regexp       // Regular expression matching layout directives.
+}
 
-	tpls := map[string]string{}
-	els := []string{}
-	ls := layouts{}
-	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+// scan traverses the file system and returns information
+// about template files it contains. Root is a path to the
+// file system's directory on disk, if any.
+func scan(s *TemplateSet, fsys fs.FS, root string) *files {
+	fl := &files{
+		set:  s,
+		fsys: fsys,
+		root: root,
+		tpls: map[string]string{},
+		ls:   layouts{},
+		mods: map[string]time.Time{},
+		deps: map[string][]string{},
+		dir:  directiveRe(s.DelimLeft, s.DelimRight),
+	}
+	fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
 		// Make sure there are no any errors.
 		if err != nil {
 			Log.Printf("Failed to traverse template files. Error: %v.", err)
@@ -27,82 +70,206 @@ func load(dir string) map[string]*template.Template {
 		}
 
 		// Ignore directories.
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
-
-		// Get current path without the dir at the beginning.
-		// E.g. if we're scanning "views/app/index.html", extract
-		// the "app/index.html" part only.
-		rel, _ := filepath.Rel(dir, p)
-		relNorm := filepath.ToSlash(rel)
+		if info, err := d.Info(); err == nil {
+			fl.mods[p] = info.ModTime()
+		}
 
 		// Check whether current file (e.g. "index.html") is a layout template.
-		b := path.Base(relNorm)
-		if b == *layoutTpl {
-			ls[path.Dir(relNorm)] = true
+		b := path.Base(p)
+		if b == s.LayoutFile {
+			fl.ls[path.Dir(p)] = p
 			return nil
 		}
 
 		// Check whether current file is a view element (e.g. "element_button.html").
-		if strings.HasPrefix(b, *elemTplPref) {
-			els = append(els, p)
+		if strings.HasPrefix(b, s.ElementPrefix) {
+			fl.els = append(fl.els, p)
 			return nil
 		}
 
 		// Otherwise, just add it to the list of templates.
-		tpls[relNorm] = p
+		fl.tpls[p] = p
 		return nil
 	})
+	return fl
+}
 
-	// Print a list of element templates.
-	Log.Printf("View elements: %v", els)
+// display returns a path of the file that is shown in logs
+// and error messages, i.e. a path on disk if the views
+// are read from disk.
+func (fl *files) display(p string) string {
+	if fl.root == "" {
+		return p
+	}
+	return filepath.Join(fl.root, filepath.FromSlash(p))
+}
 
-	// Parse templates and register them.
-	var err error
-	for relNorm, p := range tpls {
-		t := template.New(relNorm).Funcs(Funcs).Delims(*delimLeft, *delimRight)
-
-		// Check whether current template must have
-		// a layout file.
-		if l, ok := ls.path(path.Dir(relNorm)); ok {
-			m[relNorm], err = t.ParseFiles(append(els, l, p)...)
-			Log.Printf("\t%s (%s)", p, l)
-		} else {
-			m[relNorm], err = t.ParseFiles(append(els, p)...)
-			Log.Printf("\t%s", p)
+// layout returns a path of the layout that is used by the template.
+func (fl *files) layout(relNorm string) (string, bool) {
+	return fl.ls.path(path.Dir(relNorm))
+}
+
+// parse parses a template with the normalized relative path
+// together with its layouts and elements. Templates with
+// text extensions of the set are parsed using text/template.
+func (fl *files) parse(relNorm string) (executor, *ParseError) {
+	p := fl.tpls[relNorm]
+
+	// Find out the layouts the template extends.
+	src, err := fs.ReadFile(fl.fsys, p)
+	if err != nil {
+		return nil, newParseError(relNorm, fl.display(p), nil, err)
+	}
+	ls, srcs, e := fl.chain(relNorm, src)
+	fl.deps[relNorm] = ls
+	if e != nil {
+		return nil, e
+	}
+	if len(ls) > 0 {
+		ds := make([]string, len(ls))
+		for i := range ls {
+			ds[i] = fl.display(ls[len(ls)-1-i])
 		}
+		Log.Printf("\t%s (%s)", fl.display(p), strings.Join(ds, " < "))
+	} else {
+		Log.Printf("\t%s", fl.display(p))
+	}
 
-		// Make sure there were no errors during parsing.
+	// Elements are parsed first, then the layouts starting from
+	// the base one, so blocks of every next file override
+	// the ones that are defined earlier.
+	for _, f := range fl.els {
+		b, err := fs.ReadFile(fl.fsys, f)
 		if err != nil {
-			Log.Panicf(`Failed to parse "%s". Error: %v.`, p, err)
+			return nil, newParseError(relNorm, fl.display(f), nil, err)
 		}
+		srcs[f] = b
 	}
-	return m
+	ps := append(append(append([]string{}, fl.els...), ls...), p)
+	srcs[p] = src
+
+	// Parse the files the same way template.ParseFiles does,
+	// i.e. every file is associated with a template named
+	// after its base name.
+	var t executor
+	var add func(name, src string) error
+	if fl.set.isText(relNorm) {
+		tt := texttemplate.New(relNorm).Funcs(texttemplate.FuncMap(fl.set.funcs())).Delims(fl.set.DelimLeft, fl.set.DelimRight)
+		t, add = tt, func(name, src string) error {
+			tpl := tt
+			if name != tt.Name() {
+				tpl = tt.New(name)
+			}
+			_, err := tpl.Parse(src)
+			return err
+		}
+	} else {
+		ht := template.New(relNorm).Funcs(fl.set.funcs()).Delims(fl.set.DelimLeft, fl.set.DelimRight)
+		t, add = ht, func(name, src string) error {
+			tpl := ht
+			if name != ht.Name() {
+				tpl = ht.New(name)
+			}
+			_, err := tpl.Parse(src)
+			return err
+		}
+	}
+	for _, f := range ps {
+		if err := add(path.Base(f), string(srcs[f])); err != nil {
+			return nil, newParseError(relNorm, fl.display(f), srcs[f], err)
+		}
+	}
+	return t, nil
+}
+
+// chain returns paths of the layouts the template with the source
+// extends, starting from the base one, and their sources.
+// The template's layout is the one chosen by the layout directive or,
+// if there is no directive, the one found in the template's directory
+// or higher level directories. Text templates use layouts only
+// if they are chosen by the directive. Layouts may extend other layouts
+// using the directive.
+func (fl *files) chain(relNorm string, src []byte) ([]string, map[string][]byte, *ParseError) {
+	l, ok := fl.directive(src)
+	if !ok && !fl.set.isText(relNorm) {
+		l, _ = fl.layout(relNorm)
+	}
+
+	var res []string
+	srcs := map[string][]byte{}
+	seen := map[string]bool{fl.tpls[relNorm]: true}
+	for l != "" {
+		if seen[l] {
+			return res, srcs, newParseError(relNorm, fl.display(l), nil, fmt.Errorf(`layout "%s" extends itself`, l))
+		}
+		seen[l] = true
+		res = append([]string{l}, res...)
+
+		b, err := fs.ReadFile(fl.fsys, l)
+		if err != nil {
+			return res, srcs, newParseError(relNorm, fl.display(l), nil, err)
+		}
+		srcs[l] = b
+		l, _ = fl.directive(b)
+	}
+	return res, srcs, nil
+}
+
+// directive returns a path of the layout that is chosen by the directive
+// at the beginning of the source, if there is one. An empty path
+// is returned for "none". Paths are relative to the views directory.
+func (fl *files) directive(src []byte) (string, bool) {
+	m := fl.dir.FindSubmatch(src)
+	if m == nil {
+		return "", false
+	}
+	if string(m[1]) == "none" {
+		return "", true
+	}
+	return strings.TrimPrefix(path.Clean("/"+string(m[2])), "/"), true
+}
+
+// directiveRe returns a regular expression matching layout directives, i.e.
+// comments with the delimiters at the beginning of template files:
+//
+//	{%/* layout "Admin/Layout.html" */%}
+//	{%/* layout none */%}
+func directiveRe(left, right string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*` + regexp.QuoteMeta(left) + `(?:- )?/\*\s*layout\s+(none|"([^"]+)")\s*\*/(?: -)?` + regexp.QuoteMeta(right))
 }
 
 // layouts stores information about directories that have layout
 // templates. E.g., if there is "layout.html" in "app/profiles", there will be:
-//	app/profiles: true
-type layouts map[string]bool
+//
+//	app/profiles: app/profiles/layout.html
+type layouts map[string]string
 
 // path gets a template dir's path and returns associated layout template's path. E.g.:
-//	- views/
-//		+ layout.html
-//		- app/
-//			+ index.html
-//			- profiles/
-//				+ index.html
-//				+ layout.html
+//
+//	views/
+//		layout.html
+//		app/
+//			index.html
+//			profiles/
+//				index.html
+//				layout.html
+//
 // In case of the listing that's provided above, path must return:
+//
 //	layout.html
+//
 // if "app/" is provided as argument. And:
+//
 //	app/profiles/layout.html
+//
 // if "app/profiles/" is provided.
 func (l layouts) path(dir string) (string, bool) {
 	// If the requested directory has a layout file, return its path.
-	if l[dir] {
-		return filepath.Join(*views, filepath.FromSlash(dir), *layoutTpl), true
+	if p, ok := l[dir]; ok {
+		return p, true
 	}
 
 	// If it's not and this is a root directory, return.
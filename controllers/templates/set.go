@@ -0,0 +1,304 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// executor is a template parsed by html/template
+// or text/template package.
+type executor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// defines returns true if the template has an associated
+// template with the requested name.
+func defines(t executor, name string) bool {
+	switch t := t.(type) {
+	case *template.Template:
+		return t.Lookup(name) != nil
+	case *texttemplate.Template:
+		return t.Lookup(name) != nil
+	}
+	return false
+}
+
+// TemplateSet is a set of templates that are loaded from
+// a single views directory and share delimiters, layout rules,
+// and functions. Use several sets to combine e.g. an admin theme
+// and a public theme in one application:
+//
+//	var Admin = &templates.TemplateSet{Path: "./views/admin"}
+//
+//	func init() {
+//		templates.Register(Admin)
+//	}
+//
+// And choose the set in an action or magic Before method
+// of your controller:
+//
+//	c.Set = Admin
+//
+// Empty fields of sets are initialized with the values
+// of the corresponding "templates:" flags when the set is loaded.
+type TemplateSet struct {
+	// Path is a path to the directory with views.
+	// It is used if FS is nil.
+	Path string
+
+	// FS is a file system the set is loaded from.
+	FS fs.FS
+
+	// DelimLeft and DelimRight are action delimiters.
+	DelimLeft, DelimRight string
+
+	// LayoutFile is a name of layout template files and
+	// LayoutBlock is a name of the block that is rendered.
+	LayoutFile, LayoutBlock string
+
+	// ElementPrefix is a prefix of element templates' file names.
+	ElementPrefix string
+
+	// Funcs are added to the global Funcs when the templates
+	// of the set are parsed.
+	Funcs template.FuncMap
+
+	// TextExtensions are extensions of files that are parsed
+	// using text/template rather than html/template, e.g. ".txt".
+	TextExtensions []string
+
+	// ContentType is a value of the Content-Type header of responses
+	// with HTML templates. Content type of text templates is
+	// detected by their extensions.
+	ContentType string
+
+	mu        sync.RWMutex           // mu protects templates and failed maps.
+	templates map[string]executor    // Successfully parsed templates.
+	failed    map[string]*ParseError // Templates that failed to parse.
+	stop      chan struct{}          // stop is closed to stop the running watcher.
+	done      chan struct{}          // done is closed by the watcher when it exits.
+	loadMu    sync.Mutex             // loadMu serializes Load and Close.
+}
+
+// Default is a set of templates that is configured
+// using "templates:" flags and the FS package variable.
+var Default = &TemplateSet{}
+
+// sets are additional sets that are loaded by Init.
+var (
+	setsMu sync.Mutex
+	sets   []*TemplateSet
+)
+
+// Register adds the set to the list of sets that are loaded by Init.
+func Register(s *TemplateSet) {
+	setsMu.Lock()
+	sets = append(sets, s)
+	setsMu.Unlock()
+}
+
+// Load parses templates of the set and makes them available
+// for rendering. If some templates fail to parse, others are
+// loaded anyway and Errors listing the broken ones is returned.
+// In development mode the views directory is watched
+// and changed templates are reloaded automatically.
+func (s *TemplateSet) Load() error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	// Stop the running watcher first, so it does not
+	// replace the templates by outdated ones.
+	s.stopWatch()
+
+	s.defaults()
+	fl := s.files()
+	m, errs := fl.load()
+
+	s.mu.Lock()
+	s.templates, s.failed = m, errs
+	if *devMode {
+		s.stop, s.done = make(chan struct{}), make(chan struct{})
+		go s.watch(fl, *interval, s.stop, s.done)
+	}
+	s.mu.Unlock()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return sortedErrors(errs)
+}
+
+// Close stops watching of the views directory if it is running
+// and waits for the watcher to exit.
+func (s *TemplateSet) Close() {
+	s.loadMu.Lock()
+	s.stopWatch()
+	s.loadMu.Unlock()
+}
+
+// stopWatch stops the running watcher and waits for it to exit.
+func (s *TemplateSet) stopWatch() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// defaults initializes empty fields of the set using flags.
+func (s *TemplateSet) defaults() {
+	for _, v := range []struct {
+		field *string
+		def   string
+	}{
+		{&s.Path, *views},
+		{&s.DelimLeft, *delimLeft},
+		{&s.DelimRight, *delimRight},
+		{&s.LayoutFile, *layoutTpl},
+		{&s.LayoutBlock, *layoutBl},
+		{&s.ElementPrefix, *elemTplPref},
+		{&s.ContentType, *contType},
+	} {
+		if *v.field == "" {
+			*v.field = v.def
+		}
+	}
+	if s.TextExtensions == nil {
+		s.TextExtensions = textExtensions()
+	}
+}
+
+// files returns information about template files of the set.
+func (s *TemplateSet) files() *files {
+	if s.FS != nil {
+		Log.Printf(`Parsing templates in the file system.`)
+		return scan(s, s.FS, "")
+	}
+	root := filepath.Clean(s.Path)
+	Log.Printf(`Parsing templates in "%s".`, root)
+	return scan(s, os.DirFS(root), root)
+}
+
+// funcs returns functions that are available to templates of the set.
+func (s *TemplateSet) funcs() template.FuncMap {
+	m := make(template.FuncMap, len(Funcs)+len(s.Funcs))
+	for k, v := range Funcs {
+		m[k] = v
+	}
+	for k, v := range s.Funcs {
+		m[k] = v
+	}
+	return m
+}
+
+// Render renders the template with the requested path to w
+// the same way Templates controller does. It can be used
+// outside of HTTP requests, e.g. to build emails.
+func (s *TemplateSet) Render(w io.Writer, templatePath string, data interface{}) error {
+	tpl, ok := s.lookup(templatePath)
+	if !ok {
+		if e := s.parseError(templatePath); e != nil {
+			return e
+		}
+		return fmt.Errorf(`template "%s" does not exist`, templatePath)
+	}
+	return s.execute(w, tpl, templatePath, nil, false, data)
+}
+
+// RenderString is similar to Render but returns the result as a string.
+func (s *TemplateSet) RenderString(templatePath string, data interface{}) (string, error) {
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer putBuf(buf)
+	buf.Reset()
+	if err := s.Render(buf, templatePath, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// execute executes the layout block of the template with the requested
+// path. If the template has no layout or it must be rendered without one,
+// the template file itself is executed. If blocks are requested,
+// they are executed one by one instead.
+func (s *TemplateSet) execute(w io.Writer, tpl executor, name string, blocks []string, noLayout bool, data interface{}) error {
+	if len(blocks) > 0 {
+		for _, b := range blocks {
+			if err := tpl.ExecuteTemplate(w, b, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !noLayout && defines(tpl, s.LayoutBlock) {
+		return tpl.ExecuteTemplate(w, s.LayoutBlock, data)
+	}
+	return tpl.ExecuteTemplate(w, path.Base(name), data)
+}
+
+// isText returns true if the template with the requested path
+// must be parsed using text/template.
+func (s *TemplateSet) isText(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range s.TextExtensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentType returns a value of the Content-Type header
+// for the template with the requested path.
+func (s *TemplateSet) contentType(name string) string {
+	if !s.isText(name) {
+		return s.ContentType
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// variant returns a path of the first existing variant of the template
+// with one of the suffixes, e.g. "App/Index.fr.html" for "App/Index.html"
+// and "fr". If there are none, the path is returned as is.
+func (s *TemplateSet) variant(name string, suffixes []string) string {
+	ext := path.Ext(name)
+	for _, v := range suffixes {
+		p := strings.TrimSuffix(name, ext) + "." + v + ext
+		if _, ok := s.lookup(p); ok || s.parseError(p) != nil {
+			return p
+		}
+	}
+	return name
+}
+
+// lookup returns a parsed template with the requested path.
+func (s *TemplateSet) lookup(name string) (executor, bool) {
+	s.mu.RLock()
+	t, ok := s.templates[name]
+	s.mu.RUnlock()
+	return t, ok
+}
+
+// parseError returns an error of the template with the requested
+// path if it failed to parse or nil otherwise.
+func (s *TemplateSet) parseError(name string) *ParseError {
+	s.mu.RLock()
+	e := s.failed[name]
+	s.mu.RUnlock()
+	return e
+}
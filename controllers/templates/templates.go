@@ -1,15 +1,29 @@
 // Package templates provides abstractions for work
 // with standard Go template engine.
+//
+// By default, templates extend the layout file found in their directory
+// or the nearest higher level one. A template or a layout may choose
+// the layout it extends explicitly using a directive at the beginning
+// of the file, or opt out of layouts:
+//	{%/* layout "Admin/Layout.html" */%}
+//	{%/* layout none */%}
+// Paths are relative to the views directory. Layouts are parsed starting
+// from the base one, so blocks defined by templates override the defaults
+// of the "block" actions of their layouts.
 package templates
 
 import (
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 var (
@@ -25,17 +39,37 @@ var (
 	errsDir = flag.String("templates:errors.dir", "Errors", "a directory with error templates")
 
 	devMode  = flag.Bool("mode.dev", false, "development mode with debugging enabled")
+	interval = flag.Duration("templates:watch.interval", time.Second, "how often views are checked for changes in dev mode")
+	errPage  = flag.Bool("templates:dev.error.page", true, "show pages describing parse errors in dev mode instead of panicking")
 	contType = flag.String("templates:content.type", "text/html; charset=utf-8", "Content-Type header's value")
+	textExts = flag.String("templates:text.extensions", ".txt,.csv", "comma separated extensions of text/template files")
+
+	partialHdrs = flag.String("templates:partial.headers", "HX-Request", "comma separated headers of requests expecting a page fragment")
+	partialBl   = flag.String("templates:partial.block", "content", "name of the template block to render in response to fragment requests")
+	fullHdrs    = flag.String("templates:full.headers", "HX-Boosted", "comma separated headers of requests expecting the full page even if they have partial headers")
+
+	// FS is a file system templates of the Default set are loaded
+	// from, e.g. embed.FS, fstest.MapFS, or zip.Reader. If it is nil,
+	// the directory specified by "templates:path" flag is used.
+	FS fs.FS
 
 	// Funcs are added to the template's function map.
 	// Functions are expected to return just 1 argument or
 	// 2 in case the second one is of error type.
 	Funcs = template.FuncMap{}
 
+	// ContextFuncs are called when a template is rendered and
+	// their results are added to the Context under the keys
+	// of the map, unless the Context has the keys already.
+	ContextFuncs = map[string]func(r *http.Request) interface{}{}
+
+	// Variants returns suffixes of template variants that are preferred
+	// for the request, e.g. if it returns "fr", "App/Index.fr.html" is
+	// rendered instead of "App/Index.html" when the former exists.
+	Variants func(r *http.Request) []string
+
 	// Log is a default logger used by the templates controller.
 	Log = log.New(os.Stderr, "Templates: ", log.LstdFlags)
-
-	templates map[string]*template.Template
 )
 
 // Templates is a controller that provides support of HTML result
@@ -50,20 +84,50 @@ type Templates struct {
 	// If not specified explicitly, 200 will be used.
 	StatusCode int
 
+	// Stream makes templates be executed directly to the response
+	// rather than to a buffer. It saves memory when rendering very large
+	// pages, but if execution fails, the response will be truncated.
+	Stream bool
+
+	// NoLayout makes templates be rendered without their layouts,
+	// e.g. in response to AJAX requests. Template files are executed
+	// as is, so use "block" rather than "define" for the content
+	// that must be rendered in this case.
+	NoLayout bool
+
+	// Fragment is a name of the block that is rendered instead of
+	// the layout if the template defines it. It is set to the value
+	// of "templates:partial.block" flag if the request has one of
+	// the "templates:partial.headers", e.g. it is made by HTMX,
+	// and none of the "templates:full.headers", e.g. it is not
+	// a boosted navigation. Reset it to render the full page.
+	Fragment string
+
+	// Set is a set of templates that are rendered.
+	// If not specified explicitly, Default will be used.
+	Set *TemplateSet
+
 	defTpl string
 
-	Action     string `bind:"action"`
-	Controller string `bind:"controller"`
+	Action     string        `bind:"action"`
+	Controller string        `bind:"controller"`
+	Request    *http.Request `bind:"request"`
 }
 
 // Before sets a name of the template that should be rendered by default
 // (i.e. if no templates are defined explicitly). It will looks as follows:
 //	CurrentController + / + CurrentAction + .html
-// It also allocates and initializes Context.
+// It also allocates and initializes Context and chooses a Fragment
+// if the request expects one.
 func (c *Templates) Before() http.Handler {
 	// Set the default template name that's expected to be render.
 	c.defTpl = fmt.Sprintf(*defTpl, c.Controller, c.Action)
 
+	// Check whether only a fragment of the page is expected.
+	if c.Request != nil && isPartial(c.Request) {
+		c.Fragment = *partialBl
+	}
+
 	// Allocate a new context.
 	c.Context = map[string]interface{}{}
 	return nil
@@ -72,10 +136,50 @@ func (c *Templates) Before() http.Handler {
 // RenderTemplate is an action that gets a path to template
 // and renders it using data from Context.
 func (c *Templates) RenderTemplate(templatePath string) http.Handler {
+	h := c.handler(templatePath, nil)
+	h.fragment = c.Fragment
+	return h
+}
+
+// RenderPartial is an action that executes just one named block
+// of the template rather than its layout. Additional blocks are
+// rendered after the first one to the same response, so they can be used
+// for out-of-band swaps of HTMX (elements with "hx-swap-oob" attribute)
+// or Turbo Streams, e.g.:
+//	return c.RenderPartial("Cart/Index.html", "items", "counter")
+func (c *Templates) RenderPartial(templatePath, blockName string, oob ...string) http.Handler {
+	return c.handler(templatePath, append([]string{blockName}, oob...))
+}
+
+// handler returns a handler rendering the template or its variant
+// that is preferred for the request. Values of ContextFuncs
+// are added to the Context.
+func (c *Templates) handler(templatePath string, blocks []string) *Handler {
+	set := c.Set
+	if set == nil {
+		set = Default
+	}
+	if c.Context == nil {
+		c.Context = map[string]interface{}{}
+	}
+	if c.Request != nil {
+		for k, f := range ContextFuncs {
+			if _, ok := c.Context[k]; !ok {
+				c.Context[k] = f(c.Request)
+			}
+		}
+		if Variants != nil {
+			templatePath = set.variant(templatePath, Variants(c.Request))
+		}
+	}
 	return &Handler{
+		set:      set,
+		blocks:   blocks,
 		context:  c.Context,
 		status:   c.StatusCode,
 		template: templatePath,
+		stream:   c.Stream,
+		noLayout: c.NoLayout,
 	}
 }
 
@@ -125,7 +229,94 @@ func (c *Templates) Redirect(urn string) http.Handler {
 	return http.RedirectHandler(urn, http.StatusSeeOther)
 }
 
-// Init triggers loading of templates.
+// isPartial returns true if the request has one of the headers
+// specified by "templates:partial.headers" flag and none of
+// the "templates:full.headers".
+func isPartial(r *http.Request) bool {
+	return hasHeader(r, partialHeaders()) && !hasHeader(r, splitList(*fullHdrs))
+}
+
+// hasHeader returns true if the request has
+// one of the headers that is not "false".
+func hasHeader(r *http.Request, hs []string) bool {
+	for _, h := range hs {
+		if v := r.Header.Get(h); v != "" && v != "false" {
+			return true
+		}
+	}
+	return false
+}
+
+// textExtensions returns a list of "templates:text.extensions".
+func textExtensions() []string {
+	return splitList(*textExts)
+}
+
+// partialHeaders returns a list of "templates:partial.headers".
+func partialHeaders() []string {
+	return splitList(*partialHdrs)
+}
+
+// splitList splits a comma separated list ignoring empty elements.
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Init triggers loading of templates of the Default set
+// and the sets added using Register.
+// If some templates fail to parse, it panics listing all of them.
+// However, in development mode with "templates:dev.error.page" flag on
+// the errors are just logged, templates that were parsed successfully
+// are served, and pages describing the errors are shown instead of
+// the broken ones.
 func Init(_ url.Values) {
-	templates = load(*views)
+	err := Load()
+
+	setsMu.Lock()
+	ss := append([]*TemplateSet(nil), sets...)
+	setsMu.Unlock()
+	for _, s := range ss {
+		if e := s.Load(); e != nil {
+			err = appendErrors(err, e)
+		}
+	}
+
+	if err == nil {
+		return
+	}
+	if *devMode && *errPage {
+		Log.Println(err)
+		return
+	}
+	Log.Panic(err)
+}
+
+// Load configures the Default set using "templates:" flags
+// and the FS package variable, and loads it. See TemplateSet.Load.
+func Load() error {
+	Default.Close()
+	Default.Path, Default.FS = *views, FS
+	Default.DelimLeft, Default.DelimRight = *delimLeft, *delimRight
+	Default.LayoutFile, Default.LayoutBlock = *layoutTpl, *layoutBl
+	Default.ElementPrefix = *elemTplPref
+	Default.ContentType, Default.TextExtensions = *contType, textExtensions()
+	return Default.Load()
+}
+
+// Render renders a template of the Default set to w.
+// See TemplateSet.Render.
+func Render(w io.Writer, templatePath string, data interface{}) error {
+	return Default.Render(w, templatePath, data)
+}
+
+// RenderString renders a template of the Default set to a string.
+// See TemplateSet.RenderString.
+func RenderString(templatePath string, data interface{}) (string, error) {
+	return Default.RenderString(templatePath, data)
 }
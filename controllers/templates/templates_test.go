@@ -0,0 +1,474 @@
+package templates
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/goaltools/contrib/controllers/requestids"
+)
+
+func init() {
+	Log = log.New(ioutil.Discard, "", 0)
+}
+
+func TestInit_Watch(t *testing.T) {
+	dir := testViews(t, map[string]string{
+		"Layout.html":       `{%define "layout"%}A:{%template "content" .%}{%end%}`,
+		"_button.html":      `{%define "button"%}btn{%end%}`,
+		"App/Index.html":    `{%define "content"%}index {%template "button"%}{%end%}`,
+		"Users/Layout.html": `{%define "layout"%}B:{%template "content" .%}{%end%}`,
+		"Users/Show.html":   `{%define "content"%}show{%end%}`,
+		"Admin/Base.html":   `{%/* layout "Layout.html" */%}`,
+		"Admin/Index.html":  `{%/* layout "Admin/Base.html" */%}{%define "content"%}admin{%end%}`,
+	})
+	defer os.RemoveAll(dir)
+
+	*views = dir
+	*devMode = true
+	*interval = 10 * time.Millisecond
+	defer func() {
+		*devMode = false
+	}()
+	Init(url.Values{})
+
+	testRender(t, "App/Index.html", "A:index btn")
+	testRender(t, "Users/Show.html", "B:show")
+
+	// Change of an element affects all templates.
+	testWrite(t, dir, "_button.html", `{%define "button"%}BTN{%end%}`)
+	testWait(t, "App/Index.html", "A:index BTN")
+
+	// Change of a layout affects templates that use it.
+	testWrite(t, dir, "Users/Layout.html", `{%define "layout"%}C:{%template "content" .%}{%end%}`)
+	testWait(t, "Users/Show.html", "C:show")
+
+	// Removal of a layout makes templates use a higher level one.
+	os.Remove(filepath.Join(dir, "Users/Layout.html"))
+	testWait(t, "Users/Show.html", "A:show")
+
+	// Change of a layout affects templates that extend it indirectly.
+	testWrite(t, dir, "Layout.html", `{%define "layout"%}D:{%template "content" .%}{%end%}`)
+	testWait(t, "Admin/Index.html", "D:admin")
+
+	// Broken templates do not replace the working ones
+	// if error pages are off.
+	*errPage = false
+	defer func() {
+		*errPage = true
+	}()
+	testWrite(t, dir, "App/Index.html", `{%define "content"%}{%if%}{%end%}`)
+	testWrite(t, dir, "App/New.html", `{%define "content"%}new{%end%}`)
+	testWait(t, "App/New.html", "D:new")
+	testRender(t, "App/Index.html", "D:index BTN")
+}
+
+func TestTemplateSet_Close(t *testing.T) {
+	dir := testViews(t, map[string]string{
+		"App/Index.html": `{%/* layout none */%}a`,
+	})
+	defer os.RemoveAll(dir)
+	*devMode = true
+	defer func() {
+		*devMode = false
+	}()
+
+	// Watchers are stopped by Load and Close before they return.
+	set := &TemplateSet{Path: dir}
+	for i := 0; i < 2; i++ {
+		if err := set.Load(); err != nil {
+			t.Fatal(err)
+		}
+		done := set.done
+		if i == 0 {
+			set.Load()
+		} else {
+			set.Close()
+		}
+		select {
+		case <-done:
+		default:
+			t.Errorf("Iteration %d: watcher is expected to exit.", i)
+		}
+	}
+	if set.stop != nil || set.done != nil {
+		t.Error("No watchers are expected to be running after Close.")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := testViews(t, map[string]string{
+		"Layout.html":     `{%define "layout"%}A:{%template "content" .%}{%end%}`,
+		"App/Index.html":  `{%define "content"%}index{%end%}`,
+		"App/Broken.html": "{%define \"content\"%}\n\tx {%if }}%}{%end%}\n{%end%}",
+		"Users/Show.html": `{%define "content"%}{%end%}`,
+		"Users/Layout.html": `{%define "layout"%}
+{%template "content" .%}
+{%end`,
+	})
+	defer os.RemoveAll(dir)
+
+	*views = dir
+	err := Load()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v.", err)
+	}
+	for i, exp := range []struct {
+		tpl, file    string
+		line, column int
+	}{
+		{"App/Broken.html", "App/Broken.html", 2, 9},
+		{"Users/Show.html", "Users/Layout.html", 3, 0},
+	} {
+		e := errs[i]
+		if e.Template != exp.tpl || e.File != filepath.Join(dir, exp.file) || e.Line != exp.line || e.Column != exp.column {
+			t.Errorf("Incorrect error %d: %#v.", i, e)
+		}
+	}
+
+	// Templates that were parsed successfully are available.
+	testRender(t, "App/Index.html", "A:index")
+
+	// Errors are described in dev mode.
+	*devMode = true
+	defer func() {
+		*devMode = false
+	}()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	(&Handler{template: "App/Broken.html"}).ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Broken.html:2:9") {
+		t.Errorf("Expected an error page, got %d:\n%s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoad_FS(t *testing.T) {
+	FS = fstest.MapFS{
+		"Layout.html":    {Data: []byte(`{%define "layout"%}A:{%template "content" .%}{%end%}`)},
+		"_el.html":       {Data: []byte(`{%define "el"%}el{%end%}`)},
+		"App/Index.html": {Data: []byte(`{%define "content"%}index {%template "el"%}{%end%}`)},
+		"App/Bad.html":   {Data: []byte(`{%define "content"%}{%end`)},
+	}
+	defer func() {
+		FS = nil
+	}()
+
+	err := Load()
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 || errs[0].File != "App/Bad.html" {
+		t.Errorf("Expected an error of App/Bad.html, got %v.", err)
+	}
+	testRender(t, "App/Index.html", "A:index el")
+}
+
+func TestLoad_Layouts(t *testing.T) {
+	FS = fstest.MapFS{
+		"Layout.html":       {Data: []byte(`{%define "layout"%}A:{%block "title" .%}def{%end%}:{%template "content" .%}{%end%}`)},
+		"Admin/Base.html":   {Data: []byte(`{%/* layout "Layout.html" */%}{%define "title"%}admin{%end%}`)},
+		"Admin/Index.html":  {Data: []byte(`{%/* layout "/Admin/Base.html" */%}{%define "content"%}index{%end%}`)},
+		"Admin/Title.html":  {Data: []byte(`{%/* layout "Admin/Base.html" */%}{%define "title"%}own{%end%}{%define "content"%}x{%end%}`)},
+		"App/Index.html":    {Data: []byte(`{%define "content"%}app{%end%}`)},
+		"App/Partial.html":  {Data: []byte(`{%/* layout none */%}partial`)},
+		"App/Fragment.html": {Data: []byte(`{%block "content" .%}fragment{%end%}`)},
+		"Loop/A.html":       {Data: []byte(`{%/* layout "Loop/B.html" */%}`)},
+		"Loop/B.html":       {Data: []byte(`{%/* layout "Loop/A.html" */%}`)},
+	}
+	defer func() {
+		FS = nil
+	}()
+
+	err := Load()
+	if errs, ok := err.(Errors); !ok || len(errs) != 2 {
+		t.Errorf("Expected errors of the templates extending each other, got %v.", err)
+	}
+	testRender(t, "Admin/Index.html", "A:admin:index")
+	testRender(t, "Admin/Title.html", "A:own:x")
+	testRender(t, "App/Index.html", "A:def:app")
+	testRender(t, "App/Partial.html", "partial")
+	testRender(t, "App/Fragment.html", "A:def:fragment")
+
+	c := &Templates{NoLayout: true}
+	c.Before()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	c.RenderTemplate("App/Fragment.html").ServeHTTP(w, r)
+	if act, exp := w.Body.String(), "fragment"; act != exp {
+		t.Errorf(`Expected "%s", got "%s".`, exp, act)
+	}
+}
+
+func TestTemplates_RenderPartial(t *testing.T) {
+	FS = fstest.MapFS{
+		"Layout.html":    {Data: []byte(`{%define "layout"%}A:{%template "content" .%}{%end%}`)},
+		"App/Index.html": {Data: []byte(`{%define "content"%}index{%end%}{%define "counter"%}:{%.n%}{%end%}`)},
+		"App/Plain.html": {Data: []byte(`{%/* layout none */%}plain`)},
+	}
+	defer func() {
+		FS = nil
+	}()
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(hdr string, f func(c *Templates) http.Handler) string {
+		r, _ := http.NewRequest("GET", "/", nil)
+		for _, h := range strings.Split(hdr, ",") {
+			if h != "" {
+				r.Header.Set(h, "true")
+			}
+		}
+		c := &Templates{Request: r}
+		c.Before()
+		c.Context["n"] = 3
+		w := httptest.NewRecorder()
+		f(c).ServeHTTP(w, r)
+		if w.Header().Get("Vary") != "HX-Request" {
+			t.Errorf("Expected Vary header, got %v.", w.Header())
+		}
+		return w.Body.String()
+	}
+	for _, v := range []struct {
+		hdr string
+		f   func(c *Templates) http.Handler
+		exp string
+	}{
+		{"", func(c *Templates) http.Handler { return c.RenderTemplate("App/Index.html") }, "A:index"},
+		{"HX-Request", func(c *Templates) http.Handler { return c.RenderTemplate("App/Index.html") }, "index"},
+		{"HX-Request", func(c *Templates) http.Handler {
+			c.Fragment = ""
+			return c.RenderTemplate("App/Index.html")
+		}, "A:index"},
+		{"HX-Request,HX-Boosted", func(c *Templates) http.Handler { return c.RenderTemplate("App/Index.html") }, "A:index"},
+		{"HX-Request", func(c *Templates) http.Handler { return c.RenderTemplate("App/Plain.html") }, "plain"},
+		{"", func(c *Templates) http.Handler { return c.RenderPartial("App/Index.html", "counter") }, ":3"},
+		{"", func(c *Templates) http.Handler { return c.RenderPartial("App/Index.html", "content", "counter") }, "index:3"},
+		{"", func(c *Templates) http.Handler { return c.RenderPartial("App/Index.html", "unknown") }, "500 Internal Server Error\n"},
+	} {
+		if act := render(v.hdr, v.f); act != v.exp {
+			t.Errorf(`Header "%s": expected "%s", got "%s".`, v.hdr, v.exp, act)
+		}
+	}
+}
+
+func TestRenderString(t *testing.T) {
+	FS = fstest.MapFS{
+		"Layout.html":           {Data: []byte(`{%define "layout"%}A:{%template "content" .%}{%end%}`)},
+		"Emails/Welcome.html":   {Data: []byte(`{%define "content"%}hi {%.%}{%end%}`)},
+		"Emails/Base.txt":       {Data: []byte(`{%define "layout"%}T:{%template "content" .%}{%end%}`)},
+		"Emails/Welcome.txt":    {Data: []byte(`{%/* layout "Emails/Base.txt" */%}{%define "content"%}hi {%.%}{%end%}`)},
+		"Emails/NoLayout.txt":   {Data: []byte(`plain {%.%}`)},
+		"Exports/Users.csv":     {Data: []byte(`{%range .%}{%.%},{%end%}`)},
+		"Emails/Broken.txt":     {Data: []byte(`{%if%}`)},
+		"Emails/_Signature.txt": {Data: []byte(`{%define "signature"%}sig{%end%}`)},
+	}
+	defer func() {
+		FS = nil
+	}()
+	Load()
+
+	for _, v := range []struct {
+		tpl  string
+		data interface{}
+		exp  string
+	}{
+		{"Emails/Welcome.html", "<b>", "A:hi &lt;b&gt;"},
+		{"Emails/Welcome.txt", "<b>", "T:hi <b>"},
+		{"Emails/NoLayout.txt", "<b>", "plain <b>"},
+		{"Exports/Users.csv", []string{"a", "b"}, "a,b,"},
+	} {
+		if act, err := RenderString(v.tpl, v.data); err != nil || act != v.exp {
+			t.Errorf(`Template "%s": expected "%s", got "%s", %v.`, v.tpl, v.exp, act, err)
+		}
+	}
+	for _, tpl := range []string{"Emails/Broken.txt", "Emails/Unknown.txt"} {
+		if _, err := RenderString(tpl, nil); err == nil {
+			t.Errorf(`Template "%s": error expected.`, tpl)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	(&Handler{template: "Exports/Users.csv", context: map[string]interface{}{}}).ServeHTTP(w, r)
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Expected CSV content type, got %s.", ct)
+	}
+}
+
+func TestTemplates_Variants(t *testing.T) {
+	FS = fstest.MapFS{
+		"App/Index.html":    {Data: []byte(`{%.lang%}`)},
+		"App/Index.fr.html": {Data: []byte(`fr {%.lang%}`)},
+	}
+	ContextFuncs["lang"] = func(r *http.Request) interface{} {
+		return r.Header.Get("Accept-Language")
+	}
+	Variants = func(r *http.Request) []string {
+		return []string{r.Header.Get("Accept-Language")}
+	}
+	defer func() {
+		FS, Variants = nil, nil
+		delete(ContextFuncs, "lang")
+	}()
+	Load()
+
+	for lang, exp := range map[string]string{"fr": "fr fr", "de": "de"} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", lang)
+		c := &Templates{Request: r}
+		c.Before()
+		w := httptest.NewRecorder()
+		c.RenderTemplate("App/Index.html").ServeHTTP(w, r)
+		if act := w.Body.String(); act != exp {
+			t.Errorf(`Language "%s": expected "%s", got "%s".`, lang, exp, act)
+		}
+	}
+}
+
+func TestTemplateSet(t *testing.T) {
+	Funcs["name"] = func() string { return "default" }
+	defer delete(Funcs, "name")
+	s := &TemplateSet{
+		FS: fstest.MapFS{
+			"Base.html":      {Data: []byte(`[[define "page"]]admin:[[template "content" .]][[end]]`)},
+			"App/Index.html": {Data: []byte(`[[define "content"]][[name]][[end]]`)},
+		},
+		DelimLeft:   "[[",
+		DelimRight:  "]]",
+		LayoutFile:  "Base.html",
+		LayoutBlock: "page",
+		Funcs: template.FuncMap{
+			"name": func() string { return "admin" },
+		},
+	}
+	Register(s)
+	defer func() {
+		sets = nil
+	}()
+
+	FS = fstest.MapFS{
+		"Layout.html":    {Data: []byte(`{%define "layout"%}A:{%template "content" .%}{%end%}`)},
+		"App/Index.html": {Data: []byte(`{%define "content"%}{%name%}{%end%}`)},
+	}
+	defer func() {
+		FS = nil
+	}()
+	Init(url.Values{})
+
+	testRender(t, "App/Index.html", "A:default")
+
+	c := &Templates{Set: s}
+	c.Before()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	c.RenderTemplate("App/Index.html").ServeHTTP(w, r)
+	if act, exp := w.Body.String(), "admin:admin"; act != exp {
+		t.Errorf(`Expected "%s", got "%s".`, exp, act)
+	}
+}
+
+func TestHandler_ExecutionError(t *testing.T) {
+	dir := testViews(t, map[string]string{
+		"Layout.html":     `{%define "layout"%}A:{%template "content" .%}{%end%}`,
+		"App/Index.html":  `{%define "content"%}index {%.x.y%}{%end%}`,
+		"Errors/500.html": `{%define "content"%}error {%if .error%}{%.error%}{%end%}{%end%}`,
+	})
+	defer os.RemoveAll(dir)
+	*views = dir
+	Load()
+
+	for _, v := range []struct {
+		dev, stream bool
+		status      int
+		exp         string
+	}{
+		{false, false, 500, "A:error "},
+		{true, false, 500, "A:error template: Index.html:1:"},
+		{false, true, 200, "A:index "},
+	} {
+		*devMode = v.dev
+		c := &Templates{Stream: v.stream}
+		c.Before()
+		c.Context["x"] = 1
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		c.RenderTemplate("App/Index.html").ServeHTTP(w, r)
+		if w.Code != v.status || !strings.HasPrefix(w.Body.String(), v.exp) {
+			t.Errorf("Dev: %v, stream: %v. Expected %d %q, got %d %q.", v.dev, v.stream, v.status, v.exp, w.Code, w.Body.String())
+		}
+	}
+	*devMode = false
+}
+
+func TestLogf(t *testing.T) {
+	defer func() {
+		Log = log.New(ioutil.Discard, "", 0)
+	}()
+	var buf strings.Builder
+	Log = log.New(&buf, "", 0)
+
+	w := httptest.NewRecorder()
+	w.Header().Set(requestids.Header, "x.html%s%d")
+	r, _ := http.NewRequest("GET", "/", nil)
+	logf(w, r, `Template "%s" does not exist.`, "App/Index.html")
+	if exp := "[x.html%s%d] Template \"App/Index.html\" does not exist.\n"; buf.String() != exp {
+		t.Errorf("Expected %q, got %q.", exp, buf.String())
+	}
+}
+
+func testViews(t *testing.T, fs map[string]string) string {
+	dir, err := ioutil.TempDir("", "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range fs {
+		testWrite(t, dir, name, content)
+	}
+	return dir
+}
+
+func testWrite(t *testing.T, dir, name, content string) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	os.MkdirAll(filepath.Dir(p), 0755)
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make sure modification time differs from the previous one
+	// on file systems with low resolution of timestamps.
+	mt := time.Now().Add(time.Duration(len(content)) * time.Second)
+	os.Chtimes(p, mt, mt)
+}
+
+func testRender(t *testing.T, tpl, exp string) {
+	if act := testDo(tpl); act != exp {
+		t.Errorf(`Template "%s": expected "%s", got "%s".`, tpl, exp, act)
+	}
+}
+
+func testWait(t *testing.T, tpl, exp string) {
+	for i := 0; i < 100; i++ {
+		if testDo(tpl) == exp {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testRender(t, tpl, exp)
+}
+
+func testDo(tpl string) string {
+	c := &Templates{}
+	c.Before()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	c.RenderTemplate(tpl).ServeHTTP(w, r)
+	return w.Body.String()
+}
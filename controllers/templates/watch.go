@@ -0,0 +1,132 @@
+package templates
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// watch checks modification times of the files in the views directory
+// every interval until the stop channel is closed. When the files are changed,
+// affected templates are parsed again and the templates map is replaced.
+// The done channel is closed when the watcher exits.
+func (s *TemplateSet) watch(fl *files, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		// Check whether there are any changes.
+		nfl := scan(s, fl.fsys, fl.root)
+		changed := diff(fl.mods, nfl.mods)
+		if len(changed) == 0 {
+			continue
+		}
+		Log.Printf("Changed template files: %v.", changed)
+
+		s.mu.RLock()
+		m := make(map[string]executor, len(s.templates))
+		for k, v := range s.templates {
+			m[k] = v
+		}
+		errs := make(map[string]*ParseError, len(s.failed))
+		for k, v := range s.failed {
+			errs[k] = v
+		}
+		s.mu.RUnlock()
+
+		// Remove the deleted templates.
+		for relNorm := range m {
+			if _, ok := nfl.tpls[relNorm]; !ok {
+				delete(m, relNorm)
+			}
+		}
+		for relNorm := range errs {
+			if _, ok := nfl.tpls[relNorm]; !ok {
+				delete(errs, relNorm)
+			}
+		}
+
+		// Parse the affected templates. If there are errors and
+		// error pages are off, the last valid version of the template is used.
+		// Others keep the information about their layouts.
+		for relNorm, ls := range fl.deps {
+			nfl.deps[relNorm] = ls
+		}
+		for _, relNorm := range affected(fl, nfl, changed) {
+			t, err := nfl.parse(relNorm)
+			if err != nil {
+				Log.Println(err)
+				if *errPage {
+					delete(m, relNorm)
+					errs[relNorm] = err
+				}
+				continue
+			}
+			m[relNorm] = t
+			delete(errs, relNorm)
+		}
+
+		s.mu.Lock()
+		s.templates, s.failed = m, errs
+		s.mu.Unlock()
+		fl = nfl
+	}
+}
+
+// diff returns a set of paths that were added,
+// removed, or modified.
+func diff(old, cur map[string]time.Time) map[string]bool {
+	res := map[string]bool{}
+	for p, t := range cur {
+		if ot, ok := old[p]; !ok || !ot.Equal(t) {
+			res[p] = true
+		}
+	}
+	for p := range old {
+		if _, ok := cur[p]; !ok {
+			res[p] = true
+		}
+	}
+	return res
+}
+
+// affected returns normalized relative paths of the templates
+// that must be parsed again because of the changed files.
+// If an element is changed, all templates are affected as elements
+// are parsed into every template. If a layout is changed, templates
+// that extend it or extended it before are affected.
+func affected(old, cur *files, changed map[string]bool) []string {
+	all := false
+	for p := range changed {
+		if strings.HasPrefix(path.Base(p), cur.set.ElementPrefix) {
+			all = true
+			break
+		}
+	}
+
+	var res []string
+	for relNorm, p := range cur.tpls {
+		ol, _ := old.layout(relNorm)
+		nl, _ := cur.layout(relNorm)
+		if all || changed[p] || changed[nl] || ol != nl || anyChanged(old.deps[relNorm], changed) {
+			res = append(res, relNorm)
+		}
+	}
+	return res
+}
+
+// anyChanged returns true if any of the paths is changed.
+func anyChanged(ps []string, changed map[string]bool) bool {
+	for _, p := range ps {
+		if changed[p] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+// Package accesslog implements access logging of HTTP requests.
+// It is a hook of the denco router, so log entries include
+// matched route patterns. Entries may be written in Apache
+// combined, JSON lines, or logfmt formats, custom formats can
+// be registered using Formats.
+//
+// A sample of its usage is below:
+//
+//	l, err := accesslog.New(os.Stdout)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	router := r.NewRouter().Hook(l)
+//
+// Behaviour of the logger is configured using the following flags:
+//
+//	[log]
+//	format = json
+//	sample = 0.1
+//	exclude = /healthz,/static/
+package accesslog
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+var (
+	format  = flag.String("log:format", "combined", `format of access log entries: "combined", "json", or "logfmt"`)
+	sample  = flag.Float64("log:sample", 1, "fraction of successful requests that are logged, from 0 to 1")
+	exclude = flag.String("log:exclude", "", "comma separated list of path prefixes that are not logged")
+)
+
+// Entry contains information about a served request.
+type Entry struct {
+	Time       time.Time     // Time when the request was received.
+	Duration   time.Duration // Duration of the request's serving.
+	RemoteAddr string        // IP address of the client.
+	User       string        // Username from Basic authentication, if any.
+	Method     string
+	URI        string
+	Proto      string
+	Route      string // Matched route pattern, empty if no route was found. See r.RoutePattern.
+	RequestID  string
+	Referer    string
+	UserAgent  string
+	Status     int   // Status code of the response.
+	Bytes      int64 // Size of the response body.
+}
+
+// Format appends a representation of the entry to the buffer
+// and returns the extended buffer. Trailing new line is added
+// by the logger.
+type Format func(buf []byte, e *Entry) []byte
+
+// Formats are name -> format pairs. Names are expected
+// as values of "log:format" flag. Add an element to the map
+// to register a custom format.
+var Formats = map[string]Format{
+	"combined": Combined,
+	"json":     JSON,
+	"logfmt":   Logfmt,
+}
+
+// RequestID returns an identifier of the request that is
+// included into log entries. By default the value of
+// X-Request-ID header of the response or the request is used.
+var RequestID = func(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get("X-Request-ID"); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-ID")
+}
+
+// Logger is a router hook that writes an entry to Out
+// for every request.
+type Logger struct {
+	Out    io.Writer
+	Format Format
+
+	// Sample is a fraction of requests that are logged,
+	// 1 means all of them. Responses with status codes 500
+	// and above are always logged.
+	Sample float64
+
+	// Exclude is a list of path prefixes of requests
+	// that must not be logged.
+	Exclude []string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// New allocates and returns a logger that writes entries to w
+// and is configured using "log:" flags.
+func New(w io.Writer) (*Logger, error) {
+	f, ok := Formats[*format]
+	if !ok {
+		return nil, fmt.Errorf(`accesslog: unknown format "%s"`, *format)
+	}
+	l := &Logger{
+		Out:    w,
+		Format: f,
+		Sample: *sample,
+	}
+	for _, p := range strings.Split(*exclude, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			l.Exclude = append(l.Exclude, p)
+		}
+	}
+	return l, nil
+}
+
+// Dispatch is used to implement denco.Hook interface.
+func (l *Logger) Dispatch(w http.ResponseWriter, req *http.Request, pattern string, next http.Handler) {
+	if l.excluded(req.URL.Path) {
+		next.ServeHTTP(w, req)
+		return
+	}
+
+	start := time.Now()
+	rec := r.NewRecorder(w)
+	next.ServeHTTP(rec, req)
+
+	status := rec.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < http.StatusInternalServerError && l.Sample < 1 && rand.Float64() >= l.Sample {
+		return
+	}
+
+	user, _, _ := req.BasicAuth()
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	l.Write(&Entry{
+		Time:       start,
+		Duration:   time.Since(start),
+		RemoteAddr: host,
+		User:       user,
+		Method:     req.Method,
+		URI:        req.RequestURI,
+		Proto:      req.Proto,
+		Route:      r.RoutePattern(pattern),
+		RequestID:  RequestID(rec, req),
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+		Status:     status,
+		Bytes:      rec.Bytes,
+	})
+}
+
+// Handler returns a handler that logs requests served by h.
+// It is used for logging of handlers that are not served
+// by the denco router, route patterns of entries are empty.
+func (l *Logger) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		l.Dispatch(w, req, "", h)
+	})
+}
+
+// Write formats the entry and writes it to Out.
+func (l *Logger) Write(e *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.Format(l.buf[:0], e), '\n')
+	_, err := l.Out.Write(l.buf)
+	return err
+}
+
+// excluded checks whether the path must not be logged.
+func (l *Logger) excluded(path string) bool {
+	for i := range l.Exclude {
+		if strings.HasPrefix(path, l.Exclude[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+var testEntry = &Entry{
+	Time:       time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+	Duration:   1500 * time.Microsecond,
+	RemoteAddr: "127.0.0.1",
+	User:       "frank",
+	Method:     "GET",
+	URI:        "/a.gif",
+	Proto:      "HTTP/1.0",
+	Route:      "/:file",
+	RequestID:  "abc",
+	UserAgent:  `Mozilla "5.0"`,
+	Status:     200,
+	Bytes:      2326,
+}
+
+func TestFormats(t *testing.T) {
+	for _, v := range []struct {
+		f   Format
+		exp string
+	}{
+		{
+			Combined,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "-" "Mozilla \"5.0\""`,
+		},
+		{
+			Logfmt,
+			`time=2000-10-10T13:55:36-07:00 duration_ms=1.5 remote_addr=127.0.0.1 user=frank method=GET ` +
+				`uri=/a.gif proto=HTTP/1.0 route=/:file request_id=abc referer="" user_agent="Mozilla \"5.0\"" ` +
+				`status=200 bytes=2326`,
+		},
+	} {
+		if act := string(v.f(nil, testEntry)); act != v.exp {
+			t.Errorf("Incorrect entry. Expected:\n%s\nGot:\n%s", v.exp, act)
+		}
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(JSON(nil, testEntry), &m); err != nil {
+		t.Fatalf("Entry is not a valid JSON. Error: %v.", err)
+	}
+	if m["route"] != "/:file" || m["duration_ms"] != 1.5 || m["request_id"] != "abc" {
+		t.Errorf("Incorrect JSON entry %v.", m)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	*format = "json"
+	*exclude = "/static/, /healthz"
+	buf := &bytes.Buffer{}
+	l, err := New(buf)
+	if err != nil {
+		t.Fatalf("Failed to create a logger. Error: %v.", err)
+	}
+
+	router := r.NewRouter().Hook(l)
+	err = router.Handle(r.Routes{
+		r.Get("/users/:name", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "xyz")
+			fmt.Fprint(w, "Hello")
+		}),
+		r.Get("/static/*filepath", func(w http.ResponseWriter, r *http.Request) {}),
+	}).Mount("/admin", http.NotFoundHandler()).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router. Error: %v.", err)
+	}
+	for _, p := range []string{"/users/john", "/static/app.css", "/qwerty", "/admin/users"} {
+		req, _ := http.NewRequest("GET", p, nil)
+		req.RequestURI = p
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 entries, got %d:\n%s", len(lines), buf.String())
+	}
+	for i, exp := range []map[string]interface{}{
+		{"uri": "/users/john", "route": "/users/:name", "status": 200.0, "bytes": 5.0, "request_id": "xyz"},
+		{"uri": "/qwerty", "route": "", "status": 404.0},
+		{"uri": "/admin/users", "route": "/admin/*", "status": 404.0},
+	} {
+		var m map[string]interface{}
+		json.Unmarshal([]byte(lines[i]), &m)
+		for k, v := range exp {
+			if m[k] != v {
+				t.Errorf(`Entry %d: expected "%s" to be %v, got %v.`, i, k, v, m[k])
+			}
+		}
+	}
+
+	*format = "qwerty"
+	if _, err := New(buf); err == nil {
+		t.Errorf("Expected an error for unknown format.")
+	}
+}
@@ -0,0 +1,106 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Combined is Apache combined log format:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://ex.com/" "Mozilla/5.0"
+//
+// Route and request ID are not included as they are not part of the format.
+func Combined(buf []byte, e *Entry) []byte {
+	buf = append(buf, dash(e.RemoteAddr)...)
+	buf = append(buf, " - "...)
+	buf = append(buf, dash(e.User)...)
+	buf = append(buf, " ["...)
+	buf = e.Time.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	buf = append(buf, "] "...)
+	buf = strconv.AppendQuote(buf, e.Method+" "+e.URI+" "+e.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(e.Status), 10)
+	buf = append(buf, ' ')
+	if e.Bytes == 0 {
+		buf = append(buf, '-')
+	} else {
+		buf = strconv.AppendInt(buf, e.Bytes, 10)
+	}
+	buf = append(buf, ' ')
+	buf = strconv.AppendQuote(buf, dash(e.Referer))
+	buf = append(buf, ' ')
+	return strconv.AppendQuote(buf, dash(e.UserAgent))
+}
+
+// JSON formats entries as JSON objects, one per line.
+func JSON(buf []byte, e *Entry) []byte {
+	b, _ := json.Marshal(&struct {
+		Time       string  `json:"time"`
+		DurationMS float64 `json:"duration_ms"`
+		RemoteAddr string  `json:"remote_addr"`
+		User       string  `json:"user,omitempty"`
+		Method     string  `json:"method"`
+		URI        string  `json:"uri"`
+		Proto      string  `json:"proto"`
+		Route      string  `json:"route"`
+		RequestID  string  `json:"request_id,omitempty"`
+		Referer    string  `json:"referer,omitempty"`
+		UserAgent  string  `json:"user_agent,omitempty"`
+		Status     int     `json:"status"`
+		Bytes      int64   `json:"bytes"`
+	}{
+		e.Time.Format(time.RFC3339Nano), ms(e.Duration), e.RemoteAddr, e.User, e.Method, e.URI,
+		e.Proto, e.Route, e.RequestID, e.Referer, e.UserAgent, e.Status, e.Bytes,
+	})
+	return append(buf, b...)
+}
+
+// Logfmt formats entries as logfmt key=value pairs.
+func Logfmt(buf []byte, e *Entry) []byte {
+	for i, kv := range [][2]string{
+		{"time", e.Time.Format(time.RFC3339Nano)},
+		{"duration_ms", strconv.FormatFloat(ms(e.Duration), 'f', -1, 64)},
+		{"remote_addr", e.RemoteAddr},
+		{"user", e.User},
+		{"method", e.Method},
+		{"uri", e.URI},
+		{"proto", e.Proto},
+		{"route", e.Route},
+		{"request_id", e.RequestID},
+		{"referer", e.Referer},
+		{"user_agent", e.UserAgent},
+		{"status", strconv.Itoa(e.Status)},
+		{"bytes", strconv.FormatInt(e.Bytes, 10)},
+	} {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, kv[0]...)
+		buf = append(buf, '=')
+		if kv[1] == "" || strings.ContainsAny(kv[1], " =\"\\") || strings.IndexFunc(kv[1], isControl) >= 0 {
+			buf = strconv.AppendQuote(buf, kv[1])
+			continue
+		}
+		buf = append(buf, kv[1]...)
+	}
+	return buf
+}
+
+// ms returns the duration in milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// dash returns "-" if the string is empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
@@ -26,9 +26,21 @@
 //		}
 //		log.Fatal(http.ListenAndServe(":8080", router))
 //	}
+//
+// Any http.Handler, including another Router, may be mounted
+// under a prefix. The prefix is stripped from paths of requests
+// the handler gets, so it must expect paths relative to the prefix:
+//
+//	router.Mount("/assets", http.FileServer(http.Dir("./public")))
+//	router.Mount("/admin", adminRouter) // adminRouter has "/users/:id" route.
+//
+// Handlers that match full paths, e.g. http.DefaultServeMux with
+// its "/debug/pprof/" patterns, must not be mounted. Their original
+// paths are available through OriginalPath, though.
 package denco
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
@@ -38,9 +50,17 @@ import (
 
 // Router represents a multiplexer for HTTP requests.
 type Router struct {
+	// NotFound and MethodNotAllowed are router specific error handlers.
+	// If they are nil, handlers of the router this one is mounted to
+	// are used. If the router is not mounted, package level
+	// NotFound and MethodNotAllowed are used.
+	NotFound, MethodNotAllowed http.HandlerFunc
+
 	data    *denco.Router  // data stores denco router.
 	indexes map[string]int // indexes is used to simplify search of records we need.
 	records []denco.Record // records is a list of handlers expected by denco router.
+	parent  *Router        // parent is a router this one is mounted to.
+	hooks   []Hook         // hooks are called around every dispatch of a request.
 }
 
 // Routes is an alias of []Route.
@@ -49,8 +69,14 @@ type Routes []*Route
 // Route is used to store information about HTTP request's handler
 // including a list of allowed methods and pattern.
 type Route struct {
-	Handlers *Dict  // HTTP request method -> handler pairs.
-	Pattern  string // Pattern is a routing path for handler.
+	Handlers *Dict        // HTTP request method -> handler pairs.
+	Pattern  string       // Pattern is a routing path for handler.
+	Mount    http.Handler // Mount is a handler for requests of any method, see Mount.
+
+	// Labels are HTTP request method -> label pairs. Labels are
+	// assigned to routes by Build function and can be used
+	// as identifiers of the routes' handlers.
+	Labels map[string]string
 }
 
 // Dict is a dictionary structure that is used by routing package instead of map
@@ -132,7 +158,13 @@ func Build(rs []struct {
 		case "405":
 			MethodNotAllowed = rs[i].Handler
 		}
-		ls = append(ls, Do(rs[i].Method, rs[i].Pattern, rs[i].Handler))
+		r := Do(rs[i].Method, rs[i].Pattern, rs[i].Handler)
+		if rs[i].Label != "" {
+			r.Labels = map[string]string{
+				strings.ToUpper(rs[i].Method): rs[i].Label,
+			}
+		}
+		ls = append(ls, r)
 		continue
 	}
 	r := NewRouter().Handle(ls)
@@ -170,8 +202,31 @@ func Delete(pattern string, handler http.HandlerFunc) *Route {
 // It dispatches the request to the handler whose pattern
 // most closely matches the request URL.
 func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h, _ := t.Handler(r)
-	h.ServeHTTP(w, r)
+	h, pattern := t.Handler(r)
+	if len(t.hooks) == 0 {
+		h.ServeHTTP(w, r)
+		return
+	}
+	t.dispatch(0, w, r, pattern, h)
+}
+
+// Hook registers hooks that will be called around every dispatch
+// of a request in order of their registration.
+func (t *Router) Hook(hs ...Hook) *Router {
+	t.hooks = append(t.hooks, hs...)
+	return t
+}
+
+// dispatch calls the hook with index i, the next hooks are
+// called by it through the next handler. The last one calls h.
+func (t *Router) dispatch(i int, w http.ResponseWriter, r *http.Request, pattern string, h http.Handler) {
+	if i == len(t.hooks) {
+		h.ServeHTTP(w, r)
+		return
+	}
+	t.hooks[i].Dispatch(w, r, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.dispatch(i+1, w, r, pattern, h)
+	}))
 }
 
 // Handle registers handlers for given patterns.
@@ -196,10 +251,45 @@ func (t *Router) Handle(routes Routes) *Router {
 		// Otherwise, just add new HTTP methods to the existing route.
 		r := t.records[index].Value.(*Route)
 		r.Handlers.Join(routes[i].Handlers)
+		if routes[i].Mount != nil {
+			r.Mount = routes[i].Mount
+		}
+		for k, v := range routes[i].Labels {
+			if r.Labels == nil {
+				r.Labels = map[string]string{}
+			}
+			r.Labels[k] = v
+		}
 	}
 	return t
 }
 
+// Routes returns a list of routes registered by the router
+// in order of their registration.
+func (t *Router) Routes() Routes {
+	rs := make(Routes, len(t.records))
+	for i := range t.records {
+		rs[i] = t.records[i].Value.(*Route)
+	}
+	return rs
+}
+
+// Mount registers a handler that serves all requests starting with
+// the prefix, whatever method they use. The prefix is stripped from
+// the request's path before the handler is called, the original path
+// is available through OriginalPath. Prefix may contain parameters,
+// e.g. "/users/:id/files".
+// If the handler is a *Router, it inherits NotFound and MethodNotAllowed
+// handlers of this router unless it has its own ones.
+// Routes that are registered for the prefix explicitly take precedence
+// over the mounted handler.
+func (t *Router) Mount(prefix string, h http.Handler) *Router {
+	if r, ok := h.(*Router); ok {
+		r.parent = t
+	}
+	return t.Handle(Mount(prefix, h))
+}
+
 // Build compiles registered routes. Routes that are added after building will not
 // be handled. A new call to build will be required.
 func (t *Router) Build() error {
@@ -224,6 +314,20 @@ func Do(method, pattern string, handler http.HandlerFunc) *Route {
 	}
 }
 
+// Mount allocates and returns routes that are necessary for
+// serving all requests starting with the prefix by the handler.
+// Use Router.Mount if the handler is a *Router.
+func Mount(prefix string, h http.Handler) Routes {
+	prefix = strings.TrimSuffix(prefix, "/")
+	rs := Routes{
+		{Handlers: NewDict(), Pattern: prefix + "/*" + MountParam, Mount: h},
+	}
+	if prefix != "" {
+		rs = append(rs, &Route{Handlers: NewDict(), Pattern: prefix, Mount: h})
+	}
+	return rs
+}
+
 // Handler returns the handler to use for the given request, consulting r.Method
 // and r.URL.Path. It always returns a non-nil handler. If there is no registered handler
 // that applies to the request, Handler returns a “page not found” handler and empty pattern.
@@ -233,19 +337,31 @@ func (t *Router) Handler(r *http.Request) (handler http.Handler, pattern string)
 	// Make sure we have a handler for this request.
 	obj, params, found := t.data.Lookup(r.URL.Path)
 	if !found {
-		return http.HandlerFunc(NotFound), ""
+		return t.notFound(), ""
 	}
 
 	// Check whether requested method is allowed.
+	// If it is not, but there is a mounted handler, use it.
 	route := obj.(*Route)
 	handler, i := route.Handlers.Get(r.Method)
-	if i == -1 {
-		return http.HandlerFunc(MethodNotAllowed), route.Pattern
+	mount := i == -1
+	if mount && route.Mount == nil {
+		return t.methodNotAllowed(), route.Pattern
+	}
+
+	// Parameters of mounted handlers' prefixes are added to the Form
+	// of the request copy they get, so the request is not touched.
+	if mount {
+		return mounted(route.Mount, params), route.Pattern
 	}
 
 	// Add parameters of request to request.Form and return a handler.
+	// Requests that came through a mounted handler keep parameters
+	// of the parents' prefixes.
 	if len(params) > 0 {
-		r.Form = make(url.Values, len(params))
+		if _, ok := r.Context().Value(originalURLKey{}).(*url.URL); !ok || r.Form == nil {
+			r.Form = make(url.Values, len(params))
+		}
 		for i := range params {
 			r.Form[params[i].Name] = []string{params[i].Value}
 		}
@@ -253,16 +369,135 @@ func (t *Router) Handler(r *http.Request) (handler http.Handler, pattern string)
 	return handler, route.Pattern
 }
 
+// MountParam is a name of the wildcard parameter that is used
+// for extraction of mounted handlers' paths. Patterns of
+// mounted routes end with "/*" + MountParam.
+const MountParam = "_mountpath"
+
+// RoutePattern returns the pattern with the wildcard of mounted
+// handlers' paths shortened to "*", e.g. "/admin/*" for routes
+// that were created by Mount. Use it for patterns that are shown
+// to users, e.g. in logs and metrics.
+func RoutePattern(pattern string) string {
+	return strings.TrimSuffix(pattern, MountParam)
+}
+
+// originalURLKey is a key of request context's value
+// that stores URL of the request before any prefix was stripped.
+type originalURLKey struct{}
+
+// OriginalPath returns a path of the request as it was received
+// by the top level router, i.e. before prefixes of mounted
+// handlers were stripped.
+func OriginalPath(r *http.Request) string {
+	if u, ok := r.Context().Value(originalURLKey{}).(*url.URL); ok {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+// mounted returns a handler that calls h with a copy of the request
+// whose path is replaced by the value of MountParam. The copy has
+// its own Form with the parsed query and body of the request and
+// the other params, so parameters that are added by h do not leak
+// to the parent.
+func mounted(h http.Handler, params denco.Params) http.Handler {
+	path := "/"
+	for i := range params {
+		if params[i].Name == MountParam {
+			path += params[i].Value
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// Remember the original URL unless some parent
+		// router has already done it.
+		ctx := r.Context()
+		if _, ok := ctx.Value(originalURLKey{}).(*url.URL); !ok {
+			u := *r.URL
+			ctx = context.WithValue(ctx, originalURLKey{}, &u)
+		}
+
+		r2 := r.WithContext(ctx)
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		r2.Form = make(url.Values, len(r.Form)+len(params))
+		for k, v := range r.Form {
+			r2.Form[k] = v
+		}
+		for i := range params {
+			if params[i].Name != MountParam {
+				r2.Form[params[i].Name] = []string{params[i].Value}
+			}
+		}
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// unmounted returns a copy of the request with the original URL
+// restored if the request was passed through a mounted handler.
+func unmounted(r *http.Request) *http.Request {
+	u, ok := r.Context().Value(originalURLKey{}).(*url.URL)
+	if !ok {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *u
+	return r2
+}
+
+// notFound returns a NotFound handler of the router.
+func (t *Router) notFound() http.Handler {
+	return t.errorHandler(func(r *Router) http.HandlerFunc { return r.NotFound }, NotFound)
+}
+
+// methodNotAllowed returns a MethodNotAllowed handler of the router.
+func (t *Router) methodNotAllowed() http.Handler {
+	return t.errorHandler(func(r *Router) http.HandlerFunc { return r.MethodNotAllowed }, MethodNotAllowed)
+}
+
+// errorHandler returns the handler of the router that is obtained using get.
+// If the router has no such handler, the one of its parent is used
+// with the original URL of the request restored.
+// If there is no parent, def is returned.
+func (t *Router) errorHandler(get func(*Router) http.HandlerFunc, def http.HandlerFunc) http.Handler {
+	if h := get(t); h != nil {
+		return h
+	}
+	if t.parent == nil {
+		return def
+	}
+	h := t.parent.errorHandler(get, def)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, unmounted(r))
+	})
+}
+
 // MethodNotAllowed replies to the request with an HTTP 405 method not allowed
 // error. If you want to use your own MethodNotAllowed handler, please override
 // this variable.
 var MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	http.Error(w, withRequestID(w, "405 method not allowed"), http.StatusMethodNotAllowed)
 }
 
 // NotFound replies to the request with an HTTP 404 not found error.
 // NotFound is called when unknown HTTP method or a handler not found.
 // If you want to use the your own NotFound handler, please overwrite this variable.
 var NotFound = func(w http.ResponseWriter, r *http.Request) {
-	http.NotFound(w, r)
+	http.Error(w, withRequestID(w, "404 page not found"), http.StatusNotFound)
+}
+
+// withRequestID adds an ID of the request to the message
+// if the response has X-Request-ID header, e.g. set by
+// a hook of the router.
+func withRequestID(w http.ResponseWriter, msg string) string {
+	if id := w.Header().Get("X-Request-ID"); id != "" {
+		return msg + " (request ID: " + id + ")"
+	}
+	return msg
 }
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -186,3 +187,184 @@ func testHandlerFuncHelloWorld(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Hello, world!\n")
 	testHandlerFunc(w, r)
 }
+
+func TestRouter_Mount(t *testing.T) {
+	admin := NewRouter()
+	err := admin.Handle(Routes{
+		Get("/", testHandlerFunc),
+		Get("/users/:name", testHandlerFunc),
+	}).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a mounted router. Error: %s.", err)
+	}
+
+	r := NewRouter()
+	r.NotFound = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "not found: %s", r.URL.Path)
+	}
+	err = r.Handle(Routes{
+		Get("/", testHandlerFunc),
+	}).Mount("/admin", admin).Mount("/files/:bucket", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "path: %s, original: %s, form: %v", r.URL.Path, OriginalPath(r), r.Form)
+		},
+	)).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router with mounted handlers. Error: %s.", err)
+	}
+
+	for _, v := range []struct {
+		status                 int
+		method, path, expected string
+	}{
+		{
+			200, "GET", "/admin",
+			fmt.Sprintf("method: GET, path: /, form: %v", url.Values(nil)),
+		},
+		{
+			200, "GET", "/admin/users/john",
+			fmt.Sprintf("method: GET, path: /users/john, form: %v", url.Values{
+				"name": {"john"},
+			}),
+		},
+		{
+			200, "POST", "/files/photos/2015/cat.jpg",
+			fmt.Sprintf("path: /2015/cat.jpg, original: /files/photos/2015/cat.jpg, form: %v", url.Values{
+				"bucket": {"photos"},
+			}),
+		},
+		{
+			404, "GET", "/admin/qwerty", "not found: /admin/qwerty",
+		},
+		{
+			404, "GET", "/qwerty", "not found: /qwerty",
+		},
+		{
+			405, "POST", "/admin/users/john", "405 method not allowed\n",
+		},
+	} {
+		req, _ := http.NewRequest(v.method, v.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if actual := w.Body.String(); w.Code != v.status || actual != v.expected {
+			t.Errorf(
+				`%s "%s" => %#v %#v, expected %#v %#v.`,
+				v.method, v.path, w.Code, actual, v.status, v.expected,
+			)
+		}
+	}
+}
+
+func TestRouter_MountParams(t *testing.T) {
+	files := NewRouter()
+	err := files.Handle(Routes{
+		Get("/:name", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "form: %v", r.Form)
+		}),
+	}).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a mounted router. Error: %s.", err)
+	}
+
+	r := NewRouter()
+	err = r.Mount("/users/:id/files", files).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router with a mounted router. Error: %s.", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/users/1/files/a.txt", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	exp := fmt.Sprintf("form: %v", url.Values{"id": {"1"}, "name": {"a.txt"}})
+	if act := w.Body.String(); act != exp {
+		t.Errorf("Parameters of the prefix are expected to be kept, expected %#v, got %#v.", exp, act)
+	}
+	if _, ok := req.Form["name"]; ok {
+		t.Errorf("Parameters of the mounted router are not expected to leak to the parent, got %v.", req.Form)
+	}
+}
+
+func TestRouter_MountForm(t *testing.T) {
+	r := NewRouter()
+	err := r.Mount("/debug/:section", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.FormValue("section"), r.FormValue("seconds"), r.PostFormValue("name"))
+	})).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router with a mounted handler. Error: %s.", err)
+	}
+
+	for _, v := range []struct {
+		method, path, body, expected string
+	}{
+		{"GET", "/debug/pprof/profile?seconds=5", "", "pprof 5 "},
+		{"POST", "/debug/pprof/profile?seconds=5", "name=john", "pprof 5 john"},
+	} {
+		req, _ := http.NewRequest(v.method, v.path, strings.NewReader(v.body))
+		if v.body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if actual := w.Body.String(); actual != v.expected {
+			t.Errorf(`%s "%s": expected %#v, got %#v.`, v.method, v.path, v.expected, actual)
+		}
+	}
+}
+
+func TestRouter_Hook(t *testing.T) {
+	var log []string
+	hook := func(name string) Hook {
+		return HookFunc(func(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler) {
+			log = append(log, name+" before "+pattern)
+			rec := NewRecorder(w)
+			next.ServeHTTP(rec, r)
+			log = append(log, fmt.Sprintf("%s after %d %d", name, rec.Status, rec.Bytes))
+		})
+	}
+
+	r := NewRouter().Hook(hook("a"), hook("b"))
+	err := r.Handle(Routes{
+		Get("/profile/:name", testHandlerFuncHelloWorld),
+	}).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router. Error: %s.", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/profile/john", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	exp := []string{
+		"a before /profile/:name",
+		"b before /profile/:name",
+		"b after 200 70",
+		"a after 200 70",
+	}
+	if !reflect.DeepEqual(log, exp) {
+		t.Errorf("Expected hooks to be called as %v, got %v.", exp, log)
+	}
+}
+
+func TestRouter_ErrorsRequestID(t *testing.T) {
+	r := NewRouter().Hook(HookFunc(func(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler) {
+		w.Header().Set("X-Request-ID", "abc")
+		next.ServeHTTP(w, r)
+	}))
+	err := r.Handle(Routes{Get("/", testHandlerFunc)}).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router. Error: %s.", err)
+	}
+
+	for _, v := range []struct {
+		method, path, expected string
+	}{
+		{"GET", "/qwerty", "404 page not found (request ID: abc)\n"},
+		{"POST", "/", "405 method not allowed (request ID: abc)\n"},
+	} {
+		req, _ := http.NewRequest(v.method, v.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != v.expected {
+			t.Errorf(`%s "%s" => %#v, expected %#v.`, v.method, v.path, w.Body.String(), v.expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package denco
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Hook is an interface of instrumentation hooks of the router.
+// Dispatch is called for every request with the matched pattern
+// (an empty string if no route was found). It must call next
+// to continue dispatching of the request. Writer and request
+// passed to next may differ from the original ones, e.g. they may
+// be wrapped to record the response.
+type Hook interface {
+	Dispatch(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler)
+}
+
+// HookFunc is an adapter that allows use of ordinary functions
+// as the router's hooks.
+type HookFunc func(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler)
+
+// Dispatch calls f(w, r, pattern, next).
+func (f HookFunc) Dispatch(w http.ResponseWriter, r *http.Request, pattern string, next http.Handler) {
+	f(w, r, pattern, next)
+}
+
+// Recorder is an http.ResponseWriter that records status code
+// and size of the response. It is supposed to be used by hooks.
+type Recorder struct {
+	http.ResponseWriter
+
+	Status int   // Status code of the response, 0 if nothing has been written yet.
+	Bytes  int64 // Bytes is a number of bytes of the body written so far.
+}
+
+// NewRecorder returns a Recorder wrapping the writer.
+// If the writer is a *Recorder already, it is returned as is
+// so several hooks may share it.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	if rec, ok := w.(*Recorder); ok {
+		return rec
+	}
+	return &Recorder{ResponseWriter: w}
+}
+
+// WriteHeader records the status code and calls WriteHeader
+// of the wrapped writer.
+func (w *Recorder) WriteHeader(code int) {
+	if w.Status == 0 {
+		w.Status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of written bytes and calls Write
+// of the wrapped writer.
+func (w *Recorder) Write(b []byte) (int, error) {
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *Recorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.Status == 0 {
+			w.Status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does.
+func (w *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("denco: wrapped ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap returns the wrapped writer, it is used by http.ResponseController.
+func (w *Recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+// NewHook returns a router hook that registers the following
+// metrics in the registry and collects them:
+//
+//	http_requests_total{method, route, code} - counter of served requests;
+//	http_request_duration_seconds{method, route} - histogram of latencies.
+//
+// The route label is a matched route pattern, it is empty
+// if no route was found. Routes of mounted handlers are reported
+// as their prefixes followed by "/*", see r.RoutePattern. Methods that are not standard are
+// reported as "OTHER".
+func NewHook(reg *Registry) r.Hook {
+	total := reg.Counter(
+		"http_requests_total", "Total number of HTTP requests.", "method", "route", "code",
+	)
+	duration := reg.Histogram(
+		"http_request_duration_seconds", "Latencies of HTTP requests in seconds.", nil, "method", "route",
+	)
+	return r.HookFunc(func(w http.ResponseWriter, req *http.Request, pattern string, next http.Handler) {
+		start := time.Now()
+		rec := r.NewRecorder(w)
+		next.ServeHTTP(rec, req)
+
+		status := rec.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m, route := method(req.Method), r.RoutePattern(pattern)
+		total.Inc(m, route, strconv.Itoa(status))
+		duration.Observe(time.Since(start).Seconds(), m, route)
+	})
+}
+
+// method returns the method if it is a standard one
+// or "OTHER" otherwise, so arbitrary methods cannot
+// increase the number of time series.
+func method(m string) string {
+	switch m {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE":
+		return m
+	}
+	return "OTHER"
+}
@@ -0,0 +1,343 @@
+// Package metrics is an in-process registry of counters and histograms
+// that are exposed in Prometheus text format. It also provides
+// a hook for the denco router that collects request metrics
+// broken down by matched route patterns rather than raw URLs,
+// so the number of time series stays bounded.
+//
+// A sample of its usage is below:
+//
+//	router := r.NewRouter().Hook(metrics.NewHook(metrics.Default))
+//	router.Handle(routes).Mount("/metrics", metrics.Default.Handler())
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds of histogram buckets (in seconds)
+// that are suitable for measuring latencies of HTTP requests.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is a registry that is used by default.
+var Default = NewRegistry()
+
+// Registry stores a set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is an interface implemented by all registered metrics.
+type metric interface {
+	write(w io.Writer) error
+}
+
+// NewRegistry allocates and returns a new registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: map[string]metric{},
+	}
+}
+
+// Counter registers a new counter with the name, help message,
+// and names of labels. If a counter with such name has already
+// been registered, it is returned. It panics if another metric
+// with the same name exists.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if c, ok := m.(*Counter); ok {
+			return c
+		}
+		panic(fmt.Sprintf(`metrics: "%s" is registered with another type`, name))
+	}
+	c := &Counter{family: newFamily(name, help, labels)}
+	r.metrics[name] = c
+	return c
+}
+
+// Histogram registers a new histogram with the name, help message,
+// upper bounds of buckets (in increasing order), and names of labels.
+// If buckets are nil, DefaultBuckets are used. If a histogram with such
+// name has already been registered, it is returned. It panics
+// if another metric with the same name exists.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if h, ok := m.(*Histogram); ok {
+			return h
+		}
+		panic(fmt.Sprintf(`metrics: "%s" is registered with another type`, name))
+	}
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{family: newFamily(name, help, labels), buckets: buckets}
+	r.metrics[name] = h
+	return h
+}
+
+// WriteTo writes all registered metrics to w using
+// Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	ns := make([]string, 0, len(r.metrics))
+	for n := range r.metrics {
+		ns = append(ns, n)
+	}
+	ms := make([]metric, len(ns))
+	sort.Strings(ns)
+	for i := range ns {
+		ms[i] = r.metrics[ns[i]]
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	for i := range ms {
+		if err := ms[i].write(cw); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// Handler returns an HTTP handler that serves the registered
+// metrics in text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// Counter is a metric whose value can only increase.
+type Counter struct {
+	*family
+}
+
+// Inc increments the counter with the label values by 1.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds the value to the counter with the label values.
+// It panics if the value is negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.mu.Lock()
+	s := c.series(values, 1)
+	s.values[0] += v
+	c.mu.Unlock()
+}
+
+// Value returns a current value of the counter with the label values.
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.data[key(values)]; ok {
+		return s.values[0]
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	for _, s := range c.sorted() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels(s, "", ""), format(s.values[0])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram is a metric that counts observed values in buckets.
+type Histogram struct {
+	*family
+	buckets []float64
+}
+
+// Observe adds the value to the histogram with the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	// Values of the series are: counts of buckets,
+	// count of all observations, and their sum.
+	s := h.series(values, len(h.buckets)+2)
+	for i := range h.buckets {
+		if v <= h.buckets[i] {
+			s.values[i]++
+		}
+	}
+	s.values[len(h.buckets)]++
+	s.values[len(h.buckets)+1] += v
+	h.mu.Unlock()
+}
+
+// Count returns a number of values observed by the histogram
+// with the label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.data[key(values)]; ok {
+		return uint64(s.values[len(h.buckets)])
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	n := len(h.buckets)
+	for _, s := range h.sorted() {
+		for i := range h.buckets {
+			_, err := fmt.Fprintf(
+				w, "%s_bucket%s %s\n", h.name, h.labels(s, "le", format(h.buckets[i])), format(s.values[i]),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(
+			w, "%s_bucket%s %s\n%s_sum%s %s\n%s_count%s %s\n",
+			h.name, h.labels(s, "le", "+Inf"), format(s.values[n]),
+			h.name, h.labels(s, "", ""), format(s.values[n+1]),
+			h.name, h.labels(s, "", ""), format(s.values[n]),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// family is a set of time series of a metric with the same name
+// that differ by values of labels.
+type family struct {
+	mu    sync.Mutex
+	name  string
+	help  string
+	names []string
+	data  map[string]*series
+}
+
+// series is a single time series of a metric.
+type series struct {
+	labels []string
+	values []float64
+}
+
+// newFamily allocates and returns a new family.
+func newFamily(name, help string, labels []string) *family {
+	return &family{
+		name:  name,
+		help:  help,
+		names: labels,
+		data:  map[string]*series{},
+	}
+}
+
+// series returns a time series with the label values allocating it
+// if necessary. Size is the number of values of a new series.
+// It panics if the number of values does not match
+// the number of labels. The family must be locked.
+func (f *family) series(values []string, size int) *series {
+	if len(values) != len(f.names) {
+		panic(fmt.Sprintf(`metrics: "%s" expects %d label values, got %d`, f.name, len(f.names), len(values)))
+	}
+	k := key(values)
+	s, ok := f.data[k]
+	if !ok {
+		s = &series{
+			labels: append([]string(nil), values...),
+			values: make([]float64, size),
+		}
+		f.data[k] = s
+	}
+	return s
+}
+
+// sorted returns the series of the family ordered by their labels.
+func (f *family) sorted() []*series {
+	ks := make([]string, 0, len(f.data))
+	for k := range f.data {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	ss := make([]*series, len(ks))
+	for i := range ks {
+		ss[i] = f.data[ks[i]]
+	}
+	return ss
+}
+
+// header writes HELP and TYPE lines of the family.
+func (f *family) header(w io.Writer, typ string) error {
+	if f.help != "" {
+		r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n", f.name, r.Replace(f.help)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "# TYPE %s %s\n", f.name, typ)
+	return err
+}
+
+// labels returns formatted labels of the series. If extra
+// is not empty, an additional label is appended.
+func (f *family) labels(s *series, extra, value string) string {
+	if len(f.names) == 0 && extra == "" {
+		return ""
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	ls := make([]string, 0, len(f.names)+1)
+	for i := range f.names {
+		ls = append(ls, fmt.Sprintf(`%s="%s"`, f.names[i], r.Replace(s.labels[i])))
+	}
+	if extra != "" {
+		ls = append(ls, fmt.Sprintf(`%s="%s"`, extra, value))
+	}
+	return "{" + strings.Join(ls, ",") + "}"
+}
+
+// key returns a unique key of the label values.
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// format returns a representation of the value that is used
+// by text exposition format.
+func format(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the number of written bytes.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
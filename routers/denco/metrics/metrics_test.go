@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("jobs_total", "Number of jobs.\nDone.", "queue")
+	c.Inc("default")
+	c.Add(2, `say "hi"`)
+	h := reg.Histogram("job_seconds", "", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	if c != reg.Counter("jobs_total", "") {
+		t.Errorf("Expected the registered counter to be returned.")
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := reg.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write metrics. Error: %v.", err)
+	}
+	exp := `# TYPE job_seconds histogram
+job_seconds_bucket{le="0.1"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.55
+job_seconds_count 3
+# HELP jobs_total Number of jobs.\nDone.
+# TYPE jobs_total counter
+jobs_total{queue="default"} 1
+jobs_total{queue="say \"hi\""} 2
+`
+	if buf.String() != exp {
+		t.Errorf("Incorrect output. Expected:\n%s\nGot:\n%s", exp, buf.String())
+	}
+}
+
+func TestNewHook(t *testing.T) {
+	reg := NewRegistry()
+	router := r.NewRouter().Hook(NewHook(reg))
+	err := router.Handle(r.Routes{
+		r.Get("/users/:name", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "Hello")
+		}),
+		r.Post("/users/:name", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}),
+	}).Mount("/admin", http.NotFoundHandler()).Build()
+	if err != nil {
+		t.Fatalf("Failed to build a router. Error: %v.", err)
+	}
+
+	for _, v := range []struct{ method, path string }{
+		{"GET", "/admin/users"},
+		{"GET", "/users/john"},
+		{"GET", "/users/jane"},
+		{"POST", "/users/james"},
+		{"GET", "/qwerty"},
+		{"BREW", "/users/coffee"},
+	} {
+		req, _ := http.NewRequest(v.method, v.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, nil)
+	for _, s := range []string{
+		`http_requests_total{method="GET",route="/users/:name",code="200"} 2`,
+		`http_requests_total{method="POST",route="/users/:name",code="201"} 1`,
+		`http_requests_total{method="GET",route="",code="404"} 1`,
+		`http_requests_total{method="OTHER",route="/users/:name",code="405"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:name"} 2`,
+		`http_requests_total{method="GET",route="/admin/*",code="404"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Expected output to contain %s, got:\n%s", s, w.Body.String())
+		}
+	}
+}
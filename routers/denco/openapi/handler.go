@@ -0,0 +1,40 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Handler returns an HTTP handler that serves the document.
+// It is expected to be mounted, e.g.:
+//
+//	router.Mount("/openapi", openapi.Handler(doc))
+//
+// YAML is served if the requested path has ".yaml" or ".yml" extension,
+// or the Accept header mentions YAML. Otherwise, JSON is served.
+func Handler(d *Document) http.Handler {
+	js, jsErr := d.JSON()
+	ym, ymErr := d.YAML()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err, ct := js, jsErr, "application/json; charset=utf-8"
+		if wantsYAML(r) {
+			b, err, ct = ym, ymErr, "application/yaml; charset=utf-8"
+		}
+
+		// Make sure there are no errors.
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ct)
+		w.Write(b)
+	})
+}
+
+// wantsYAML checks whether the request expects YAML document.
+func wantsYAML(r *http.Request) bool {
+	if strings.HasSuffix(r.URL.Path, ".yaml") || strings.HasSuffix(r.URL.Path, ".yml") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "yaml")
+}
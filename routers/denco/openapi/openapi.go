@@ -0,0 +1,336 @@
+// Package openapi generates OpenAPI 3 documents describing
+// routes of the denco router.
+// Paths, methods, and path parameters are taken from the router,
+// everything else is optional and can be provided using Meta.
+//
+// A sample of its usage is below:
+//
+//	router := r.NewRouter()
+//	router.Handle(r.Routes{
+//		r.Get("/profiles/:username", ShowUserHandleFunc),
+//	})
+//
+//	g := &openapi.Generator{
+//		Info: openapi.Info{Title: "Profiles", Version: "1.0"},
+//		Meta: map[string]*openapi.Meta{
+//			"GET /profiles/:username": {
+//				Summary:  "Show a user profile",
+//				Response: Profile{},
+//			},
+//		},
+//	}
+//	router.Mount("/openapi", openapi.Handler(g.Generate(router)))
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+// Version is the version of OpenAPI specification
+// generated documents conform to.
+const Version = "3.0.3"
+
+// Document is a root object of OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+// Info provides metadata about the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server represents a server the API is available at.
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem describes operations available on a single path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+}
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a single operation parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes a request body.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response describes a single response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes content of a request or response body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components holds reusable objects of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Meta is optional information about a route's operation
+// that cannot be extracted from the router.
+type Meta struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Params are path parameter name -> description pairs.
+	Params map[string]string
+
+	// Query is a value of struct type whose fields are
+	// documented as query parameters. Names of the parameters
+	// are taken from "json" tags of the fields.
+	Query interface{}
+
+	// Request and Response are values whose types describe
+	// JSON bodies of request and successful response.
+	// Nil means there is no body.
+	Request, Response interface{}
+
+	// Status is a status code of successful response.
+	// If not specified explicitly, 200 will be used.
+	Status int
+}
+
+// Generator builds OpenAPI documents from routers.
+type Generator struct {
+	Info    Info
+	Servers []Server
+
+	// Meta are key -> operation metadata pairs.
+	// Key is either a label of the route (as passed to the
+	// denco.Build function) or "METHOD /pattern", e.g.:
+	//	GET /profiles/:username
+	Meta map[string]*Meta
+
+	schemas *schemas
+}
+
+// Generate returns a document describing all routes of the router.
+// Handlers mounted using Router.Mount are included if they
+// are *denco.Router, other mounted handlers are skipped.
+// Routes labeled as "404" and "405" are skipped, too.
+func (g *Generator) Generate(router *r.Router) *Document {
+	g.schemas = newSchemas()
+	d := &Document{
+		OpenAPI: Version,
+		Info:    g.Info,
+		Servers: g.Servers,
+		Paths:   map[string]*PathItem{},
+	}
+	g.routes(d, "", router)
+	if len(g.schemas.defs) > 0 {
+		d.Components = &Components{
+			Schemas: g.schemas.defs,
+		}
+	}
+	return d
+}
+
+// routes adds routes of the router to the document.
+// Prefix is added to the routes' patterns.
+func (g *Generator) routes(d *Document, prefix string, router *r.Router) {
+	suffix := "/*" + r.MountParam
+	for _, route := range router.Routes() {
+		pattern := prefix + route.Pattern
+
+		// Process routers that are mounted to the current one.
+		if route.Mount != nil && strings.HasSuffix(pattern, suffix) {
+			if sub, ok := route.Mount.(*r.Router); ok {
+				g.routes(d, strings.TrimSuffix(pattern, suffix), sub)
+			}
+		}
+
+		for _, method := range route.Handlers.Keys {
+			label := route.Labels[method]
+			if label == "404" || label == "405" {
+				continue
+			}
+			op := g.operation(method, pattern, label)
+			p := Path(pattern)
+			item := d.Paths[p]
+			if item == nil {
+				item = &PathItem{}
+			}
+
+			// Methods that are not supported by OpenAPI are ignored.
+			if item.set(method, op) {
+				d.Paths[p] = item
+			}
+		}
+	}
+}
+
+// operation allocates and returns an operation for the route.
+func (g *Generator) operation(method, pattern, label string) *Operation {
+	m := g.Meta[label]
+	if label == "" || m == nil {
+		m = g.Meta[method+" "+pattern]
+	}
+	if m == nil {
+		m = &Meta{}
+	}
+	op := &Operation{
+		OperationID: label,
+		Summary:     m.Summary,
+		Description: m.Description,
+		Tags:        m.Tags,
+		Parameters:  params(pattern, m.Params),
+		Responses:   map[string]*Response{},
+	}
+
+	// Document query parameters.
+	if m.Query != nil {
+		op.Parameters = append(op.Parameters, g.schemas.query(reflect.TypeOf(m.Query))...)
+	}
+
+	// Document the request and response bodies.
+	if m.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  g.content(m.Request),
+		}
+	}
+	status := m.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := &Response{
+		Description: http.StatusText(status),
+	}
+	if m.Response != nil {
+		res.Content = g.content(m.Response)
+	}
+	op.Responses[strconv.Itoa(status)] = res
+	return op
+}
+
+// content returns JSON content description of the value's type.
+func (g *Generator) content(v interface{}) map[string]*MediaType {
+	return map[string]*MediaType{
+		"application/json": {
+			Schema: g.schemas.schema(reflect.TypeOf(v)),
+		},
+	}
+}
+
+// set registers the operation as a handler of the method.
+// False is returned if the method is not supported by OpenAPI.
+func (p *PathItem) set(method string, op *Operation) bool {
+	switch method {
+	case "GET":
+		p.Get = op
+	case "PUT":
+		p.Put = op
+	case "POST":
+		p.Post = op
+	case "DELETE":
+		p.Delete = op
+	case "OPTIONS":
+		p.Options = op
+	case "HEAD":
+		p.Head = op
+	case "PATCH":
+		p.Patch = op
+	case "TRACE":
+		p.Trace = op
+	default:
+		return false
+	}
+	return true
+}
+
+// Path converts a router's pattern to OpenAPI path template. E.g.:
+//
+//	/users/:name/files/*filepath
+//
+// is converted to:
+//
+//	/users/{name}/files/{filepath}
+func Path(pattern string) string {
+	ss := strings.Split(pattern, "/")
+	for i := range ss {
+		if name, ok := param(ss[i]); ok {
+			ss[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(ss, "/")
+}
+
+// params returns a list of path parameters of the pattern.
+// Ds are parameter name -> description pairs.
+func params(pattern string, ds map[string]string) []*Parameter {
+	var ps []*Parameter
+	for _, s := range strings.Split(pattern, "/") {
+		name, ok := param(s)
+		if !ok {
+			continue
+		}
+		d := ds[name]
+		if d == "" && s[0] == '*' {
+			d = "The rest of the path, may contain slashes."
+		}
+		ps = append(ps, &Parameter{
+			Name:        name,
+			In:          "path",
+			Description: d,
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	return ps
+}
+
+// param checks whether a segment of pattern is a parameter
+// (i.e. ":name" or "*name") and returns its name.
+func param(segment string) (string, bool) {
+	if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+		return segment[1:], true
+	}
+	return "", false
+}
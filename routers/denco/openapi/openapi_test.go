@@ -0,0 +1,151 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	r "github.com/goaltools/contrib/routers/denco"
+)
+
+type testProfile struct {
+	Name    string         `json:"name"`
+	Email   string         `json:"email,omitempty"`
+	Created time.Time      `json:"created"`
+	Friends []*testProfile `json:"friends"`
+	secret  string
+}
+
+type testQuery struct {
+	Page int `json:"page,omitempty"`
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	admin := r.NewRouter().Handle(r.Routes{
+		r.Get("/stats", testHandlerFunc),
+	})
+	h, err := r.Build([]struct {
+		Method, Pattern, Label string
+		Handler                http.HandlerFunc
+	}{
+		{"GET", "/profiles/:username", "", testHandlerFunc},
+		{"PUT", "/profiles/:username", "Profiles.Update", testHandlerFunc},
+		{"GET", "/files/*filepath", "", testHandlerFunc},
+		{"CONNECT", "/tunnel", "", testHandlerFunc},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build a router. Error: %s.", err)
+	}
+	router := h.(*r.Router).Mount("/admin", admin)
+
+	g := &Generator{
+		Info: Info{Title: "Test", Version: "1.0"},
+		Meta: map[string]*Meta{
+			"GET /profiles/:username": {
+				Summary:  "Show a profile",
+				Query:    testQuery{},
+				Response: testProfile{},
+			},
+			"Profiles.Update": {
+				Request: &testProfile{},
+				Status:  http.StatusNoContent,
+			},
+		},
+	}
+	d := g.Generate(router)
+
+	var ps []string
+	for p := range d.Paths {
+		ps = append(ps, p)
+	}
+	exp := map[string]bool{"/profiles/{username}": true, "/files/{filepath}": true, "/admin/stats": true}
+	if len(ps) != len(exp) {
+		t.Errorf("Expected paths %v, got %v.", exp, ps)
+	}
+	for _, p := range ps {
+		if !exp[p] {
+			t.Errorf("Unexpected path %s.", p)
+		}
+	}
+
+	get := d.Paths["/profiles/{username}"].Get
+	if get.Summary != "Show a profile" || len(get.Parameters) != 2 {
+		t.Errorf("Incorrect operation: %#v.", get)
+	}
+	if get.Parameters[0].In != "path" || get.Parameters[1].In != "query" || get.Parameters[1].Required {
+		t.Errorf("Incorrect parameters: %#v, %#v.", get.Parameters[0], get.Parameters[1])
+	}
+	if ref := get.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/testProfile" {
+		t.Errorf("Incorrect response schema reference %s.", ref)
+	}
+
+	put := d.Paths["/profiles/{username}"].Put
+	if put.OperationID != "Profiles.Update" || put.RequestBody == nil || put.Responses["204"] == nil {
+		t.Errorf("Incorrect operation: %#v.", put)
+	}
+
+	sc := d.Components.Schemas["testProfile"]
+	if !reflect.DeepEqual(sc.Required, []string{"name", "created", "friends"}) {
+		t.Errorf("Incorrect required fields %v.", sc.Required)
+	}
+	if f := sc.Properties["created"]; f.Type != "string" || f.Format != "date-time" {
+		t.Errorf("Incorrect schema of time.Time: %#v.", f)
+	}
+	if f := sc.Properties["friends"]; f.Type != "array" || f.Items.Ref == "" {
+		t.Errorf("Incorrect schema of a recursive field: %#v.", f)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	d := (&Generator{Info: Info{Title: "Test: API", Version: "1"}}).Generate(
+		r.NewRouter().Handle(r.Routes{r.Get("/users/:id", testHandlerFunc)}),
+	)
+	h := Handler(d)
+
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var v map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil || v["openapi"] != Version {
+		t.Errorf("Incorrect JSON document %s. Error: %v.", w.Body.String(), err)
+	}
+
+	req, _ = http.NewRequest("GET", "/openapi.yaml", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	for _, s := range []string{
+		"openapi: \"3.0.3\"\n",
+		"  title: \"Test: API\"\n",
+		"  \"/users/{id}\":\n    get:\n      parameters:\n        -\n          in: \"path\"\n",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("Expected YAML document to contain %q, got:\n%s", s, w.Body.String())
+		}
+	}
+}
+
+func TestYAMLKey(t *testing.T) {
+	for k, exp := range map[string]string{
+		"name":  "name",
+		"x-id":  "x-id",
+		"/a/b":  `"/a/b"`,
+		"null":  `"null"`,
+		"True":  `"True"`,
+		"yes":   `"yes"`,
+		"on":    `"on"`,
+		"OFF":   `"OFF"`,
+		"n":     `"n"`,
+		"nulls": "nulls",
+	} {
+		if act := yamlKey(k); act != exp {
+			t.Errorf("Key %q: expected %s, got %s.", k, exp, act)
+		}
+	}
+}
+
+func testHandlerFunc(w http.ResponseWriter, r *http.Request) {
+}
@@ -0,0 +1,197 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Schema is a subset of OpenAPI schema object that
+// is used for description of Go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemas is a registry of named struct types' schemas.
+type schemas struct {
+	defs  map[string]*Schema      // Component name -> schema pairs.
+	names map[reflect.Type]string // Type -> component name pairs.
+}
+
+// newSchemas allocates and returns a new registry.
+func newSchemas() *schemas {
+	return &schemas{
+		defs:  map[string]*Schema{},
+		names: map[reflect.Type]string{},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns a schema of the type. Named struct types are
+// registered as components and a reference to them is returned.
+func (s *schemas) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		sc := s.schema(t.Elem())
+		if sc.Ref != "" {
+			return sc
+		}
+		sc.Nullable = true
+		return sc
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.register(t)}
+	}
+
+	// Interfaces, functions, etc. may be anything.
+	return &Schema{}
+}
+
+// register adds a named struct type to the list of components
+// and returns its name.
+func (s *schemas) register(t reflect.Type) string {
+	if n, ok := s.names[t]; ok {
+		return n
+	}
+
+	// Make sure the name is unique, types with the same names
+	// from different packages are prefixed with their package name.
+	n := componentName(t.Name())
+	if _, ok := s.defs[n]; ok {
+		n = componentName(t.String())
+	}
+	s.names[t] = n
+
+	// A placeholder is registered first so recursive types
+	// do not cause an infinite loop.
+	s.defs[n] = &Schema{}
+	*s.defs[n] = *s.object(t)
+	return n
+}
+
+// object returns a schema of the struct type.
+func (s *schemas) object(t reflect.Type) *Schema {
+	sc := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	s.fields(sc, t)
+	return sc
+}
+
+// fields adds fields of the struct type to the schema.
+// Fields of embedded structs are added as if they were
+// fields of the outer struct.
+func (s *schemas) fields(sc *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omit, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(sc, ft)
+				continue
+			}
+		}
+		sc.Properties[name] = s.schema(f.Type)
+		if !omit {
+			sc.Required = append(sc.Required, name)
+		}
+	}
+}
+
+// query returns a list of query parameters described by
+// fields of the struct type.
+func (s *schemas) query(t reflect.Type) []*Parameter {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var ps []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		name, omit, ok := jsonName(t.Field(i))
+		if !ok {
+			continue
+		}
+		ps = append(ps, &Parameter{
+			Name:     name,
+			In:       "query",
+			Required: !omit,
+			Schema:   s.schema(t.Field(i).Type),
+		})
+	}
+	return ps
+}
+
+// jsonName returns a name of the field as encoding/json
+// package would use it and whether it has "omitempty" option.
+// False is returned if the field is not encoded.
+func jsonName(f reflect.StructField) (name string, omit, ok bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	ss := strings.Split(tag, ",")
+	name = ss[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, o := range ss[1:] {
+		if o == "omitempty" {
+			omit = true
+		}
+	}
+	return name, omit, true
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// componentName replaces characters that are not allowed
+// in names of components.
+func componentName(n string) string {
+	return invalidNameChars.ReplaceAllString(n, "_")
+}
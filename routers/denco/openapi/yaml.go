@@ -0,0 +1,125 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSON returns the document encoded as JSON.
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "\t")
+}
+
+// YAML returns the document encoded as YAML.
+func (d *Document) YAML() ([]byte, error) {
+	// The document is encoded as JSON first so "json" tags
+	// are used for both formats.
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	writeYAML(buf, v, 0)
+	return buf.Bytes(), nil
+}
+
+// writeYAML writes a value obtained from JSON decoder
+// as a YAML block node.
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		ks := make([]string, 0, len(v))
+		for k := range v {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		for _, k := range ks {
+			buf.WriteString(pad + yamlKey(k) + ":")
+			writeYAMLValue(buf, v[k], indent)
+		}
+	case []interface{}:
+		for i := range v {
+			buf.WriteString(pad + "-")
+			writeYAMLValue(buf, v[i], indent)
+		}
+	}
+}
+
+// writeYAMLValue writes a value of a mapping or sequence entry.
+// Scalars and empty collections are written on the same line,
+// other values are written as nested blocks.
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if len(c) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if len(c) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+	default:
+		buf.WriteString(" " + yamlScalar(v) + "\n")
+		return
+	}
+	buf.WriteString("\n")
+	writeYAML(buf, v, indent+1)
+}
+
+// yamlScalar returns a YAML representation of a scalar value.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v)
+	}
+	return `""`
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.-]*$`)
+
+// reserved are plain scalars that YAML parsers read
+// as nulls or booleans rather than strings.
+var reserved = map[string]bool{
+	"null": true, "true": true, "false": true,
+	"yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+}
+
+// yamlKey returns a key that is quoted if necessary.
+func yamlKey(k string) string {
+	if plainKey.MatchString(k) && !reserved[strings.ToLower(k)] {
+		return k
+	}
+	return yamlString(k)
+}
+
+// yamlString returns a double quoted string. JSON escape
+// sequences are valid in YAML double quoted scalars.
+func yamlString(s string) string {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}